@@ -0,0 +1,277 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/log"
+)
+
+const clusterPeersQuery = "SELECT hostname, port FROM runtime_proxysql_servers"
+
+// discoveredInstanceTimeout is a dial timeout of discovered instances which DSNs have none,
+// so scrapes of unreachable instances do not hang.
+const discoveredInstanceTimeout = 5 * time.Second
+
+// discoverClusterPeers returns addresses of ProxySQL Cluster peers from `runtime_proxysql_servers`.
+func discoverClusterPeers(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(clusterPeersQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var peers []string
+	for rows.Next() {
+		var hostname, port string
+		if err = rows.Scan(&hostname, &port); err != nil {
+			return nil, err
+		}
+		peers = append(peers, net.JoinHostPort(hostname, port))
+	}
+	return peers, rows.Err()
+}
+
+// discoveredInstance is an exported instance found by discovery.
+type discoveredInstance struct {
+	dsn      string
+	addr     string
+	exporter *Exporter
+	gatherer *instanceGatherer
+}
+
+// discoveredInstances is a set of instances found by discovery, exported with instance label,
+// and served as Prometheus HTTP service discovery targets.
+type discoveredInstances struct {
+	newExporter func(dsn string) *Exporter
+
+	m         sync.Mutex
+	instances map[string]*discoveredInstance // key - instance name
+
+	// gathering is read-locked by in-flight gathers and write-locked before closing removed exporters
+	gathering sync.RWMutex
+}
+
+// newDiscoveredInstances returns a new empty set of instances
+// which creates exporters with given function.
+func newDiscoveredInstances(newExporter func(dsn string) *Exporter) *discoveredInstances {
+	return &discoveredInstances{
+		newExporter: newExporter,
+		instances:   make(map[string]*discoveredInstance),
+	}
+}
+
+// newInstance creates and registers exporter for given instance.
+func (d *discoveredInstances) newInstance(inst instanceConfig) (*discoveredInstance, error) {
+	cfg, err := mysql.ParseDSN(inst.DSN)
+	if err != nil {
+		return nil, err
+	}
+	exporter := d.newExporter(inst.DSN)
+	registry := prometheus.NewRegistry()
+	if err = registry.Register(lazyExporter{exporter}); err != nil {
+		exporter.Close()
+		return nil, err
+	}
+	return &discoveredInstance{
+		dsn:      inst.DSN,
+		addr:     cfg.Addr,
+		exporter: exporter,
+		gatherer: newInstanceGatherer(inst, registry),
+	}, nil
+}
+
+// update sets exported instances to given ones. Instances with changed DSN are recreated.
+// Instances which can't be created are logged and skipped.
+func (d *discoveredInstances) update(instances []instanceConfig) {
+	d.m.Lock()
+	var added []instanceConfig
+	for _, inst := range instances {
+		if cur := d.instances[inst.Name]; cur == nil || cur.dsn != inst.DSN {
+			added = append(added, inst)
+		}
+	}
+	d.m.Unlock()
+
+	created := make(map[string]*discoveredInstance, len(added))
+	for _, inst := range added {
+		c, err := d.newInstance(inst)
+		if err != nil {
+			log.Errorf("Skipping ProxySQL instance %s: %s", inst.Name, err)
+			continue
+		}
+		created[inst.Name] = c
+	}
+
+	d.m.Lock()
+	var removed []*Exporter
+	current := make(map[string]struct{}, len(instances))
+	for _, inst := range instances {
+		current[inst.Name] = struct{}{}
+		if c := created[inst.Name]; c != nil {
+			if old := d.instances[inst.Name]; old != nil {
+				removed = append(removed, old.exporter)
+			}
+			d.instances[inst.Name] = c
+			log.Infof("Discovered ProxySQL instance %s.", inst.Name)
+		}
+	}
+	for name := range d.instances {
+		if _, ok := current[name]; !ok {
+			removed = append(removed, d.instances[name].exporter)
+			delete(d.instances, name)
+			log.Infof("ProxySQL instance %s is gone.", name)
+		}
+	}
+	d.m.Unlock()
+
+	// wait for gathers which may still use removed exporters
+	if len(removed) > 0 {
+		d.gathering.Lock()
+		d.gathering.Unlock()
+	}
+	for _, e := range removed {
+		e.Close()
+	}
+}
+
+// addresses returns sorted addresses of discovered instances.
+func (d *discoveredInstances) addresses() []string {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	addrs := make([]string, 0, len(d.instances))
+	for _, inst := range d.instances {
+		addrs = append(addrs, inst.addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// check interface
+var _ prometheus.Gatherer = (*discoveredInstances)(nil)
+
+// Gather implements prometheus.Gatherer.
+func (d *discoveredInstances) Gather() ([]*dto.MetricFamily, error) {
+	d.m.Lock()
+	d.gathering.RLock()
+	defer d.gathering.RUnlock()
+	gatherers := make(instancesGatherer, 0, len(d.instances))
+	for _, inst := range d.instances {
+		gatherers = append(gatherers, inst.gatherer)
+	}
+	d.m.Unlock()
+	return gatherers.Gather()
+}
+
+// httpSDTargetGroup is a target group in Prometheus HTTP service discovery format.
+type httpSDTargetGroup struct {
+	Targets []string `json:"targets"`
+}
+
+// check interface
+var _ http.Handler = (*discoveredInstances)(nil)
+
+// ServeHTTP implements http.Handler; it serves addresses of discovered instances
+// as Prometheus HTTP service discovery targets.
+func (d *discoveredInstances) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	groups := []httpSDTargetGroup{{Targets: d.addresses()}}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(groups); err != nil {
+		log.Errorln("Error encoding service discovery targets:", err)
+	}
+}
+
+// runDiscovery calls refresh with given interval, logging errors.
+// Function never returns.
+func runDiscovery(refresh func() error, interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := refresh(); err != nil {
+			log.Errorln("Error discovering ProxySQL instances:", err)
+		}
+	}
+}
+
+// clusterDiscovery exports all ProxySQL Cluster peers of the seed instance.
+type clusterDiscovery struct {
+	*discoveredInstances
+	seedDSN string
+}
+
+// newClusterDiscovery returns a new discovery for given seed instance DSN,
+// which creates exporters for peers with given function.
+func newClusterDiscovery(seedDSN string, newExporter func(dsn string) *Exporter) *clusterDiscovery {
+	return &clusterDiscovery{
+		discoveredInstances: newDiscoveredInstances(newExporter),
+		seedDSN:             seedDSN,
+	}
+}
+
+// peerDSN returns DSN for peer address with the same credentials and parameters as seed one,
+// and default dial timeout if seed has none.
+func (d *clusterDiscovery) peerDSN(addr string) (string, error) {
+	cfg, err := mysql.ParseDSN(d.seedDSN)
+	if err != nil {
+		return "", err
+	}
+	cfg.Net = "tcp"
+	cfg.Addr = addr
+	if cfg.Timeout == 0 {
+		cfg.Timeout = discoveredInstanceTimeout
+	}
+	return cfg.FormatDSN(), nil
+}
+
+// refresh discovers peers of the seed instance. If seed has no peers, it is exported itself.
+func (d *clusterDiscovery) refresh() error {
+	db, err := sql.Open("mysql", d.seedDSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	peers, err := discoverClusterPeers(db)
+	if err != nil {
+		return err
+	}
+	if len(peers) == 0 {
+		cfg, err := mysql.ParseDSN(d.seedDSN)
+		if err != nil {
+			return err
+		}
+		peers = []string{cfg.Addr}
+	}
+
+	instances := make([]instanceConfig, len(peers))
+	for i, addr := range peers {
+		dsn, err := d.peerDSN(addr)
+		if err != nil {
+			return err
+		}
+		instances[i] = instanceConfig{Name: addr, DSN: dsn}
+	}
+	d.update(instances)
+	return nil
+}
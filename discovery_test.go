@@ -0,0 +1,176 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestDiscoverClusterPeers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	columns := []string{"hostname", "port"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("10.91.142.80", "6032").
+		AddRow("10.91.142.82", "6032")
+	mock.ExpectQuery(sanitizeQuery(clusterPeersQuery)).WillReturnRows(rows)
+	mock.ExpectQuery(sanitizeQuery(clusterPeersQuery)).WillReturnError(errors.New("error"))
+
+	peers, err := discoverClusterPeers(db)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.91.142.80:6032", "10.91.142.82:6032"}, peers)
+
+	_, err = discoverClusterPeers(db)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClusterDiscovery(t *testing.T) {
+	var dsns []string
+	d := newClusterDiscovery("stats:stats@tcp(127.0.0.1:6032)/", func(dsn string) *Exporter {
+		dsns = append(dsns, dsn)
+		return NewExporter(dsn, ExporterOptions{})
+	})
+
+	peers := func(addrs ...string) []instanceConfig {
+		var instances []instanceConfig
+		for _, addr := range addrs {
+			dsn, err := d.peerDSN(addr)
+			require.NoError(t, err)
+			instances = append(instances, instanceConfig{Name: addr, DSN: dsn})
+		}
+		return instances
+	}
+
+	// nothing listens on ports 1 and 2, so peers are down
+	d.update(peers("127.0.0.1:2", "127.0.0.1:1"))
+	d.update(peers("127.0.0.1:1", "127.0.0.1:2"))
+	assert.Equal(t, []string{"stats:stats@tcp(127.0.0.1:2)/?timeout=5s", "stats:stats@tcp(127.0.0.1:1)/?timeout=5s"}, dsns)
+
+	rec := httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest("GET", "/sd", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `[{"targets": ["127.0.0.1:1", "127.0.0.1:2"]}]`, rec.Body.String())
+
+	mfs, err := d.Gather()
+	require.NoError(t, err)
+	var instances []string
+	for _, mf := range mfs {
+		if mf.GetName() != "proxysql_up" {
+			continue
+		}
+		for _, m := range mf.Metric {
+			assert.Equal(t, float64(0), m.GetGauge().GetValue())
+			for _, l := range m.Label {
+				if l.GetName() == instanceLabel {
+					instances = append(instances, l.GetValue())
+				}
+			}
+		}
+	}
+	assert.Equal(t, []string{"127.0.0.1:1", "127.0.0.1:2"}, instances)
+
+	d.update(peers("127.0.0.1:2"))
+	assert.Equal(t, []string{"127.0.0.1:2"}, d.addresses())
+
+	// invalid instance is skipped, removed ones are still dropped
+	d.update([]instanceConfig{{Name: "127.0.0.1:1", DSN: "invalid"}, peers("127.0.0.1:3")[0]})
+	assert.Equal(t, []string{"127.0.0.1:3"}, d.addresses())
+
+	// seed timeout is kept
+	d = newClusterDiscovery("stats:stats@tcp(127.0.0.1:6032)/?timeout=1s", nil)
+	dsn, err := d.peerDSN("127.0.0.1:1")
+	require.NoError(t, err)
+	assert.Equal(t, "stats:stats@tcp(127.0.0.1:1)/?timeout=1s", dsn)
+}
+
+func TestDiscoveredInstancesCloseRemoved(t *testing.T) {
+	mocks := make(map[string]sqlmock.Sqlmock)
+	exporters := make(map[string]*Exporter)
+	d := newDiscoveredInstances(func(dsn string) *Exporter {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		mock.ExpectClose()
+		e := NewExporter(dsn, ExporterOptions{})
+		e.dbPool = db
+		mocks[dsn] = mock
+		exporters[dsn] = e
+		return e
+	})
+	closed := func(dsn string) bool {
+		e := exporters[dsn]
+		e.dbM.Lock()
+		defer e.dbM.Unlock()
+		return e.dbClosed
+	}
+
+	dsn1 := "stats:stats@tcp(127.0.0.1:1)/"
+	dsn2 := "stats:stats@tcp(127.0.0.1:2)/"
+	d.update([]instanceConfig{{Name: "127.0.0.1:1", DSN: dsn1}, {Name: "127.0.0.1:2", DSN: dsn2}})
+
+	// block gathering of the first instance
+	gatherer := d.instances["127.0.0.1:1"].gatherer
+	registry := gatherer.gatherer
+	started, release := make(chan struct{}), make(chan struct{})
+	gatherer.gatherer = prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		close(started)
+		<-release
+		return registry.Gather()
+	})
+	gathered := make(chan []*dto.MetricFamily)
+	go func() {
+		mfs, err := d.Gather()
+		assert.NoError(t, err)
+		gathered <- mfs
+	}()
+	<-started
+
+	// removed exporter is not closed while it is gathered
+	updated := make(chan struct{})
+	go func() {
+		d.update([]instanceConfig{{Name: "127.0.0.1:2", DSN: dsn2}})
+		close(updated)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, closed(dsn1))
+	assert.Equal(t, []string{"127.0.0.1:2"}, d.addresses())
+
+	close(release)
+	var up []float64
+	for _, mf := range <-gathered {
+		if mf.GetName() == "proxysql_up" {
+			for _, m := range mf.Metric {
+				up = append(up, m.GetGauge().GetValue())
+			}
+		}
+	}
+	assert.Equal(t, []float64{1, 1}, up)
+	<-updated
+	assert.True(t, closed(dsn1))
+	assert.NoError(t, mocks[dsn1].ExpectationsWereMet())
+	assert.False(t, closed(dsn2))
+}
@@ -16,8 +16,13 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -29,36 +34,69 @@ const namespace = "proxysql"
 // Exporter collects ProxySQL metrics.
 // It implements prometheus.Collector interface.
 type Exporter struct {
-	dsn                            string
-	scrapeMySQLGlobal              bool
-	scrapeMySQLConnectionPool      bool
-	scrapeMySQLConnectionList      bool
-	scrapeDetailedMySQLProcessList bool
-	scrapeMemoryMetrics            bool
-	scrapesTotal                   prometheus.Counter
-	scrapeErrorsTotal              *prometheus.CounterVec
-	lastScrapeError                prometheus.Gauge
-	lastScrapeDurationSeconds      prometheus.Gauge
-	proxysqlUp                     prometheus.Gauge
-}
-
-// NewExporter returns a new ProxySQL exporter for the provided DSN.
-// It scrapes stats_mysql_global, stats_mysql_connection_pool and stats_mysql_processlist if corresponding parameters are true.
-func NewExporter(
-	dsn string,
-	scrapeMySQLGlobal bool,
-	scrapeMySQLConnectionPool bool,
-	scrapeMySQLConnectionList bool,
-	scrapeDetailedMySQLProcessList bool,
-	scrapeMemoryMetrics bool,
-) *Exporter {
+	dsn                       string
+	dbM                       sync.Mutex
+	dbPool                    *sql.DB
+	dbReconnect               bool
+	dbClosed                  bool
+	opts                      ExporterOptions
+	monitorErrors             *monitorErrors
+	checksumChanges           *checksumChanges
+	scrapesTotal              prometheus.Counter
+	scrapeErrorsTotal         *prometheus.CounterVec
+	lastScrapeError           prometheus.Gauge
+	lastScrapeDurationSeconds prometheus.Gauge
+	proxysqlUp                prometheus.Gauge
+	reconnectsTotal           prometheus.Counter
+}
+
+// ExporterOptions contains collectors to enable and their settings.
+type ExporterOptions struct {
+	// Collectors.
+	ScrapeMySQLGlobal              bool // stats_mysql_global
+	ScrapeMySQLConnectionPool      bool // stats_mysql_connection_pool
+	ScrapeMySQLConnectionList      bool // stats_mysql_processlist
+	ScrapeDetailedMySQLProcessList bool // detailed stats_mysql_processlist
+	ScrapeMemoryMetrics            bool // stats_memory_metrics
+	ScrapeMySQLQueryDigest         bool // stats_mysql_query_digest
+	ScrapeMySQLCommandsCounters    bool // stats_mysql_commands_counters
+	ScrapeMySQLErrors              bool // stats_mysql_errors
+	ScrapeMonitor                  bool // monitor schema logs
+	ScrapeRuntimeMySQLServers      bool // runtime_mysql_servers
+	ScrapeHostgroups               bool // runtime hostgroups tables
+	ScrapeProxySQLServers          bool // stats_proxysql_servers_* tables
+	ScrapeMySQLUsers               bool // stats_mysql_users
+	ScrapeMySQLQueryRules          bool // stats_mysql_query_rules
+	ScrapeMySQLFreeConnections     bool // stats_mysql_free_connections
+	ScrapePreparedStatements       bool // stats_mysql_prepared_statements_info
+	ScrapeGTIDExecuted             bool // stats_mysql_gtid_executed
+	ScrapeStatsHistory             bool // stats_history schema
+	ScrapeGlobalVariables          bool // runtime_global_variables
+	ScrapeRuntimeChecksums         bool // runtime_checksums_values
+	ScrapeClientHostCache          bool // stats_mysql_client_host_cache
+
+	// Collectors settings.
+	QueryDigestLimit        int            // number of top query digests, the rest is folded into proxysql_query_digest_other_* gauges
+	QueryDigestOrderBy      string         // sum_time or count_star
+	QueryRulesComment       bool           // add query rule comment as a label
+	PreparedStatementsLimit int            // number of top prepared statements, 0 to disable
+	GlobalVariablesInclude  *regexp.Regexp // variable names to collect, nil to collect all
+	GlobalVariablesExclude  *regexp.Regexp // variable names to skip, nil to skip none
+	ClientHostCacheLimit    int            // number of top client hosts, 0 to disable
+
+	// Connection pool settings, see database/sql.DB.
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+}
+
+// NewExporter returns a new ProxySQL exporter for the provided DSN with given options.
+func NewExporter(dsn string, opts ExporterOptions) *Exporter {
 	return &Exporter{
-		dsn:                            dsn,
-		scrapeMySQLGlobal:              scrapeMySQLGlobal,
-		scrapeMySQLConnectionPool:      scrapeMySQLConnectionPool,
-		scrapeMySQLConnectionList:      scrapeMySQLConnectionList,
-		scrapeDetailedMySQLProcessList: scrapeDetailedMySQLProcessList,
-		scrapeMemoryMetrics:            scrapeMemoryMetrics,
+		dsn:             dsn,
+		opts:            opts,
+		monitorErrors:   newMonitorErrors(),
+		checksumChanges: newChecksumChanges(),
 
 		scrapesTotal: prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: namespace,
@@ -89,6 +127,12 @@ func NewExporter(
 			Name:      "up",
 			Help:      "Whether ProxySQL is up.",
 		}),
+		reconnectsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "reconnects_total",
+			Help:      "Total number of times the connection pool to ProxySQL was reopened after a failed health check.",
+		}),
 	}
 }
 
@@ -122,6 +166,23 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	<-doneCh
 }
 
+// lazyExporter is an Exporter which describes only exporter's own metrics, so its registration does not scrape
+// ProxySQL. It should be registered only in registry without pedantic checks of collected metrics.
+type lazyExporter struct {
+	*Exporter
+}
+
+// Describe sends descriptors of exporter's own metrics to the provided channel.
+// Part of prometheus.Collector interface.
+func (e lazyExporter) Describe(ch chan<- *prometheus.Desc) {
+	e.scrapesTotal.Describe(ch)
+	e.scrapeErrorsTotal.Describe(ch)
+	e.lastScrapeError.Describe(ch)
+	e.lastScrapeDurationSeconds.Describe(ch)
+	e.proxysqlUp.Describe(ch)
+	e.reconnectsTotal.Describe(ch)
+}
+
 // Collect is called by the Prometheus registry when collecting metrics.
 // Part of prometheus.Collector interface.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
@@ -132,14 +193,55 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	e.lastScrapeError.Collect(ch)
 	e.lastScrapeDurationSeconds.Collect(ch)
 	e.proxysqlUp.Collect(ch)
+	e.reconnectsTotal.Collect(ch)
 }
 
+// db returns a long-lived connection pool, opening it if needed, after checking its health with ping.
+// If health check fails, pool is closed and reopened on the next call.
 func (e *Exporter) db() (*sql.DB, error) {
-	db, err := sql.Open("mysql", e.dsn)
-	if err == nil {
-		err = db.Ping()
+	e.dbM.Lock()
+	defer e.dbM.Unlock()
+
+	if e.dbClosed {
+		return nil, fmt.Errorf("exporter for %s is closed", e.dsn)
+	}
+	if e.dbPool == nil {
+		db, err := sql.Open("mysql", e.dsn)
+		if err != nil {
+			return nil, err
+		}
+		db.SetMaxOpenConns(e.opts.DBMaxOpenConns)
+		db.SetMaxIdleConns(e.opts.DBMaxIdleConns)
+		db.SetConnMaxLifetime(e.opts.DBConnMaxLifetime)
+		e.dbPool = db
+	}
+
+	if err := e.dbPool.Ping(); err != nil {
+		e.dbPool.Close()
+		e.dbPool = nil
+		e.dbReconnect = true
+		return nil, err
+	}
+	if e.dbReconnect {
+		e.dbReconnect = false
+		e.reconnectsTotal.Inc()
+		log.Infoln("Reconnected to ProxySQL.")
+	}
+	return e.dbPool, nil
+}
+
+// Close closes the connection pool. Exporter should not be used after that.
+func (e *Exporter) Close() error {
+	e.dbM.Lock()
+	defer e.dbM.Unlock()
+
+	e.dbClosed = true
+	if e.dbPool == nil {
+		return nil
 	}
-	return db, err
+	err := e.dbPool.Close()
+	e.dbPool = nil
+	return err
 }
 
 func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
@@ -155,46 +257,139 @@ func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
 	}(time.Now())
 
 	db, err := e.db()
-	if db != nil {
-		defer db.Close()
-	}
 	if err != nil {
-		log.Errorln("Error opening connection to ProxySQL:", err)
+		log.Errorln("Error connecting to ProxySQL:", err)
 		e.proxysqlUp.Set(0)
 		return
 	}
 	e.proxysqlUp.Set(1)
 
-	if e.scrapeMySQLGlobal {
+	if e.opts.ScrapeMySQLGlobal {
 		if err = scrapeMySQLGlobal(db, ch); err != nil {
 			log.Errorln("Error scraping for collect.mysql_status:", err)
 			e.scrapeErrorsTotal.WithLabelValues("collect.mysql_status").Inc()
 		}
 	}
-	if e.scrapeMySQLConnectionPool {
+	if e.opts.ScrapeMySQLConnectionPool {
 		if err = scrapeMySQLConnectionPool(db, ch); err != nil {
 			log.Errorln("Error scraping for collect.mysql_connection_pool:", err)
 			e.scrapeErrorsTotal.WithLabelValues("collect.mysql_connection_pool").Inc()
 		}
 	}
-	if e.scrapeMySQLConnectionList {
+	if e.opts.ScrapeMySQLConnectionList {
 		if err = scrapeMySQLConnectionList(db, ch); err != nil {
 			log.Errorln("Error scraping for collect.mysql_connection_list:", err)
 			e.scrapeErrorsTotal.WithLabelValues("collect.mysql_connection_list").Inc()
 		}
 	}
-	if e.scrapeDetailedMySQLProcessList {
+	if e.opts.ScrapeDetailedMySQLProcessList {
 		if err = scrapeDetailedMySQLConnectionList(db, ch); err != nil {
 			log.Errorln("Error scraping for collect.stats_mysql_processlist", err)
 			e.scrapeErrorsTotal.WithLabelValues("collect.stats_mysql_processlist").Inc()
 		}
 	}
-	if e.scrapeMemoryMetrics {
+	if e.opts.ScrapeMemoryMetrics {
 		if err = scrapeMemoryMetrics(db, ch); err != nil {
 			log.Errorln("Error scraping for collect.stats_memory_metrics", err)
 			e.scrapeErrorsTotal.WithLabelValues("collect.stats_memory_metrics").Inc()
 		}
 	}
+	if e.opts.ScrapeMySQLQueryDigest {
+		if err = scrapeMySQLQueryDigest(db, ch, e.opts.QueryDigestLimit, e.opts.QueryDigestOrderBy); err != nil {
+			log.Errorln("Error scraping for collect.stats_mysql_query_digest:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.stats_mysql_query_digest").Inc()
+		}
+	}
+	if e.opts.ScrapeMySQLCommandsCounters {
+		if err = scrapeMySQLCommandsCounters(db, ch); err != nil {
+			log.Errorln("Error scraping for collect.stats_mysql_commands_counters:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.stats_mysql_commands_counters").Inc()
+		}
+	}
+	if e.opts.ScrapeMySQLErrors {
+		if err = scrapeMySQLErrors(db, ch); err != nil {
+			log.Errorln("Error scraping for collect.stats_mysql_errors:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.stats_mysql_errors").Inc()
+		}
+	}
+	if e.opts.ScrapeMonitor {
+		if err = scrapeMonitor(db, ch, e.monitorErrors); err != nil {
+			log.Errorln("Error scraping for collect.monitor:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.monitor").Inc()
+		}
+	}
+	if e.opts.ScrapeRuntimeMySQLServers {
+		if err = scrapeRuntimeMySQLServers(db, ch); err != nil {
+			log.Errorln("Error scraping for collect.runtime_mysql_servers:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.runtime_mysql_servers").Inc()
+		}
+	}
+	if e.opts.ScrapeHostgroups {
+		if err = scrapeHostgroups(db, ch); err != nil {
+			log.Errorln("Error scraping for collect.runtime_hostgroups:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.runtime_hostgroups").Inc()
+		}
+	}
+	if e.opts.ScrapeProxySQLServers {
+		if err = scrapeProxySQLServers(db, ch); err != nil {
+			log.Errorln("Error scraping for collect.stats_proxysql_servers:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.stats_proxysql_servers").Inc()
+		}
+	}
+	if e.opts.ScrapeMySQLUsers {
+		if err = scrapeMySQLUsers(db, ch); err != nil {
+			log.Errorln("Error scraping for collect.stats_mysql_users:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.stats_mysql_users").Inc()
+		}
+	}
+	if e.opts.ScrapeMySQLQueryRules {
+		if err = scrapeMySQLQueryRules(db, ch, e.opts.QueryRulesComment); err != nil {
+			log.Errorln("Error scraping for collect.stats_mysql_query_rules:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.stats_mysql_query_rules").Inc()
+		}
+	}
+	if e.opts.ScrapeMySQLFreeConnections {
+		if err = scrapeMySQLFreeConnections(db, ch); err != nil {
+			log.Errorln("Error scraping for collect.stats_mysql_free_connections:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.stats_mysql_free_connections").Inc()
+		}
+	}
+	if e.opts.ScrapePreparedStatements {
+		if err = scrapePreparedStatements(db, ch, e.opts.PreparedStatementsLimit); err != nil {
+			log.Errorln("Error scraping for collect.stats_mysql_prepared_statements_info:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.stats_mysql_prepared_statements_info").Inc()
+		}
+	}
+	if e.opts.ScrapeGTIDExecuted {
+		if err = scrapeGTIDExecuted(db, ch); err != nil {
+			log.Errorln("Error scraping for collect.stats_mysql_gtid_executed:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.stats_mysql_gtid_executed").Inc()
+		}
+	}
+	if e.opts.ScrapeStatsHistory {
+		if err = scrapeStatsHistory(db, ch); err != nil {
+			log.Errorln("Error scraping for collect.stats_history:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.stats_history").Inc()
+		}
+	}
+	if e.opts.ScrapeGlobalVariables {
+		if err = scrapeGlobalVariables(db, ch, e.opts.GlobalVariablesInclude, e.opts.GlobalVariablesExclude); err != nil {
+			log.Errorln("Error scraping for collect.runtime_global_variables:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.runtime_global_variables").Inc()
+		}
+	}
+	if e.opts.ScrapeRuntimeChecksums {
+		if err = scrapeRuntimeChecksums(db, ch, e.checksumChanges); err != nil {
+			log.Errorln("Error scraping for collect.runtime_checksums_values:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.runtime_checksums_values").Inc()
+		}
+	}
+	if e.opts.ScrapeClientHostCache {
+		if err = scrapeClientHostCache(db, ch, e.opts.ClientHostCacheLimit); err != nil {
+			log.Errorln("Error scraping for collect.stats_mysql_client_host_cache:", err)
+			e.scrapeErrorsTotal.WithLabelValues("collect.stats_mysql_client_host_cache").Inc()
+		}
+	}
 }
 
 // metric contains information about Prometheus metric.
@@ -206,28 +401,282 @@ type metric struct {
 
 const mySQLGlobalQuery = "SELECT Variable_Name, Variable_Value FROM stats_mysql_global"
 
+// mySQLGlobalMetric is a documented `stats_mysql_global` variable.
+type mySQLGlobalMetric struct {
+	metric
+	since string // the first ProxySQL version which has the variable
+}
+
 // https://github.com/sysown/proxysql/blob/master/doc/admin_tables.md#stats_mysql_global
 // key - variable name in lowercase.
-var mySQLGlobalMetrics = map[string]*metric{
-	"active_transactions": {"active_transactions", prometheus.GaugeValue,
-		"Current number of active transactions."},
-	"client_connections_aborted": {"client_connections_aborted", prometheus.CounterValue,
-		"Total number of frontend connections aborted due to invalid credential or max_connections reached."},
-	"client_connections_connected": {"client_connections_connected", prometheus.GaugeValue,
-		"Current number of frontend connections."},
-	"client_connections_created": {"client_connections_created", prometheus.CounterValue,
-		"Total number of frontend connections created so far."},
-	"client_connections_non_idle": {"client_connections_non_idle", prometheus.GaugeValue,
-		"Current number of client connections that are not idle."},
-	"proxysql_uptime": {"proxysql_uptime", prometheus.CounterValue,
-		"Uptime in seconds."},
-	"questions": {"questions", prometheus.CounterValue,
-		"Total number of queries sent from frontends."},
-	"slow_queries": {"slow_queries", prometheus.CounterValue,
-		"Total number of queries that ran for longer than the threshold in milliseconds defined in global variable mysql-long_query_time."},
+// Variables measured in nanoseconds (_nsec) and microseconds (_us) are exposed in seconds.
+var mySQLGlobalMetrics = map[string]*mySQLGlobalMetric{
+	"access_denied_max_connections": {metric{"access_denied_max_connections", prometheus.CounterValue,
+		"Total number of frontend connections refused because mysql-max_connections was reached."}, "1.4"},
+	"access_denied_max_user_connections": {metric{"access_denied_max_user_connections", prometheus.CounterValue,
+		"Total number of frontend connections refused because the user's max_connections was reached."}, "1.4"},
+	"access_denied_wrong_password": {metric{"access_denied_wrong_password", prometheus.CounterValue,
+		"Total number of frontend connections refused because of a wrong password."}, "1.4"},
+	"active_transactions": {metric{"active_transactions", prometheus.GaugeValue,
+		"Current number of active transactions."}, "1.4"},
+	"backend_lagging_during_query": {metric{"backend_lagging_during_query", prometheus.CounterValue,
+		"Total number of times a backend was found lagging more than max_replication_lag while retrieving a connection."}, "1.4"},
+	"backend_offline_during_query": {metric{"backend_offline_during_query", prometheus.CounterValue,
+		"Total number of times a backend was found offline while retrieving a connection."}, "1.4"},
+	"backend_query_time_nsec": {metric{"backend_query_time_seconds", prometheus.CounterValue,
+		"Total time spent making network calls to communicate with the backends in seconds."}, "1.4"},
+	"client_connections_aborted": {metric{"client_connections_aborted", prometheus.CounterValue,
+		"Total number of frontend connections aborted due to invalid credential or max_connections reached."}, "1.4"},
+	"client_connections_connected": {metric{"client_connections_connected", prometheus.GaugeValue,
+		"Current number of frontend connections."}, "1.4"},
+	"client_connections_created": {metric{"client_connections_created", prometheus.CounterValue,
+		"Total number of frontend connections created so far."}, "1.4"},
+	"client_connections_non_idle": {metric{"client_connections_non_idle", prometheus.GaugeValue,
+		"Current number of client connections that are not idle."}, "1.4"},
+	"com_autocommit": {metric{"com_autocommit", prometheus.CounterValue,
+		"Total number of autocommit statements received from frontends."}, "1.4"},
+	"com_autocommit_filtered": {metric{"com_autocommit_filtered", prometheus.CounterValue,
+		"Total number of autocommit statements received from frontends and not forwarded to backends."}, "1.4"},
+	"com_backend_change_user": {metric{"com_backend_change_user", prometheus.CounterValue,
+		"Total number of COM_CHANGE_USER commands sent to backends."}, "1.4"},
+	"com_backend_init_db": {metric{"com_backend_init_db", prometheus.CounterValue,
+		"Total number of COM_INIT_DB commands sent to backends."}, "1.4"},
+	"com_backend_set_names": {metric{"com_backend_set_names", prometheus.CounterValue,
+		"Total number of SET NAMES statements sent to backends."}, "1.4"},
+	"com_backend_stmt_close": {metric{"com_backend_stmt_close", prometheus.CounterValue,
+		"Total number of prepared statements closed on backends."}, "1.4"},
+	"com_backend_stmt_execute": {metric{"com_backend_stmt_execute", prometheus.CounterValue,
+		"Total number of prepared statements executed on backends."}, "1.4"},
+	"com_backend_stmt_prepare": {metric{"com_backend_stmt_prepare", prometheus.CounterValue,
+		"Total number of statements prepared on backends."}, "1.4"},
+	"com_commit": {metric{"com_commit", prometheus.CounterValue,
+		"Total number of COMMIT statements received from frontends."}, "1.4"},
+	"com_commit_filtered": {metric{"com_commit_filtered", prometheus.CounterValue,
+		"Total number of COMMIT statements received from frontends and not forwarded to backends."}, "1.4"},
+	"com_frontend_init_db": {metric{"com_frontend_init_db", prometheus.CounterValue,
+		"Total number of COM_INIT_DB commands received from frontends."}, "1.4"},
+	"com_frontend_set_names": {metric{"com_frontend_set_names", prometheus.CounterValue,
+		"Total number of SET NAMES statements received from frontends."}, "1.4"},
+	"com_frontend_stmt_close": {metric{"com_frontend_stmt_close", prometheus.CounterValue,
+		"Total number of prepared statements closed by frontends."}, "1.4"},
+	"com_frontend_stmt_execute": {metric{"com_frontend_stmt_execute", prometheus.CounterValue,
+		"Total number of prepared statements executed by frontends."}, "1.4"},
+	"com_frontend_stmt_prepare": {metric{"com_frontend_stmt_prepare", prometheus.CounterValue,
+		"Total number of statements prepared by frontends."}, "1.4"},
+	"com_frontend_use_db": {metric{"com_frontend_use_db", prometheus.CounterValue,
+		"Total number of USE statements received from frontends."}, "1.4"},
+	"com_rollback": {metric{"com_rollback", prometheus.CounterValue,
+		"Total number of ROLLBACK statements received from frontends."}, "1.4"},
+	"com_rollback_filtered": {metric{"com_rollback_filtered", prometheus.CounterValue,
+		"Total number of ROLLBACK statements received from frontends and not forwarded to backends."}, "1.4"},
+	"connpool_get_conn_failure": {metric{"connpool_get_conn_failure", prometheus.CounterValue,
+		"Total number of requests for a connection from the connection pool which failed."}, "1.4"},
+	"connpool_get_conn_immediate": {metric{"connpool_get_conn_immediate", prometheus.CounterValue,
+		"Total number of connections reused by the same thread from its local cache."}, "1.4"},
+	"connpool_get_conn_success": {metric{"connpool_get_conn_success", prometheus.CounterValue,
+		"Total number of requests for a connection from the connection pool which succeeded."}, "1.4"},
+	"connpool_memory_bytes": {metric{"connpool_memory_bytes", prometheus.GaugeValue,
+		"Current memory used by the connection pool to store connections metadata in bytes."}, "1.4"},
+	"generated_error_packets": {metric{"generated_error_packets", prometheus.CounterValue,
+		"Total number of error packets generated by ProxySQL and sent to frontends."}, "1.4"},
+	"hostgroup_locked_queries": {metric{"hostgroup_locked_queries", prometheus.CounterValue,
+		"Total number of queries refused because the connection is locked to a different hostgroup."}, "1.4"},
+	"hostgroup_locked_set_cmds": {metric{"hostgroup_locked_set_cmds", prometheus.CounterValue,
+		"Total number of SET statements which locked a connection to a hostgroup."}, "1.4"},
+	"max_connect_timeouts": {metric{"max_connect_timeouts", prometheus.CounterValue,
+		"Total number of times mysql-connect_timeout_server_max was reached while connecting to backends."}, "1.4"},
+	"mirror_concurrency": {metric{"mirror_concurrency", prometheus.GaugeValue,
+		"Current number of mirrored queries being executed."}, "1.4"},
+	"mirror_queue_length": {metric{"mirror_queue_length", prometheus.GaugeValue,
+		"Current number of mirrored queries waiting in the queue."}, "1.4"},
+	"myhgm_myconnpoll_destroy": {metric{"myhgm_myconnpoll_destroy", prometheus.CounterValue,
+		"Total number of backend connections destroyed by the hostgroups manager."}, "1.4"},
+	"myhgm_myconnpoll_get": {metric{"myhgm_myconnpoll_get", prometheus.CounterValue,
+		"Total number of requests for a connection made to the hostgroups manager."}, "1.4"},
+	"myhgm_myconnpoll_get_ok": {metric{"myhgm_myconnpoll_get_ok", prometheus.CounterValue,
+		"Total number of requests for a connection made to the hostgroups manager which succeeded."}, "1.4"},
+	"myhgm_myconnpoll_push": {metric{"myhgm_myconnpoll_push", prometheus.CounterValue,
+		"Total number of backend connections returned to the hostgroups manager."}, "1.4"},
+	"myhgm_myconnpoll_reset": {metric{"myhgm_myconnpoll_reset", prometheus.CounterValue,
+		"Total number of backend connections reset by the hostgroups manager."}, "1.4"},
+	"mysql_killed_backend_connections": {metric{"mysql_killed_backend_connections", prometheus.CounterValue,
+		"Total number of backend connections killed by ProxySQL."}, "1.4"},
+	"mysql_killed_backend_queries": {metric{"mysql_killed_backend_queries", prometheus.CounterValue,
+		"Total number of backend queries killed by ProxySQL."}, "1.4"},
+	"proxysql_uptime": {metric{"proxysql_uptime", prometheus.CounterValue,
+		"Uptime in seconds."}, "1.4"},
+	"queries_backends_bytes_recv": {metric{"queries_backends_bytes_recv", prometheus.CounterValue,
+		"Total number of bytes received from backends."}, "1.4"},
+	"queries_backends_bytes_sent": {metric{"queries_backends_bytes_sent", prometheus.CounterValue,
+		"Total number of bytes sent to backends."}, "1.4"},
+	"queries_frontends_bytes_recv": {metric{"queries_frontends_bytes_recv", prometheus.CounterValue,
+		"Total number of bytes received from frontends."}, "1.4"},
+	"queries_frontends_bytes_sent": {metric{"queries_frontends_bytes_sent", prometheus.CounterValue,
+		"Total number of bytes sent to frontends."}, "1.4"},
+	"queries_with_max_lag_ms": {metric{"queries_with_max_lag_ms", prometheus.CounterValue,
+		"Total number of queries with max_lag_ms annotation."}, "1.4"},
+	"queries_with_max_lag_ms__delayed": {metric{"queries_with_max_lag_ms__delayed", prometheus.CounterValue,
+		"Total number of queries with max_lag_ms annotation delayed because no backend met the requirement."}, "1.4"},
+	"queries_with_max_lag_ms__total_wait_time_us": {metric{"queries_with_max_lag_ms__total_wait_time_seconds", prometheus.CounterValue,
+		"Total time queries with max_lag_ms annotation waited for a backend in seconds."}, "1.4"},
+	"query_processor_time_nsec": {metric{"query_processor_time_seconds", prometheus.CounterValue,
+		"Total time spent inside the query processor to determine what action to take with the query in seconds."}, "1.4"},
+	"questions": {metric{"questions", prometheus.CounterValue,
+		"Total number of queries sent from frontends."}, "1.4"},
+	"selects_for_update__autocommit0": {metric{"selects_for_update__autocommit0", prometheus.CounterValue,
+		"Total number of SELECT FOR UPDATE statements executed with autocommit disabled."}, "1.4"},
+	"server_connections_aborted": {metric{"server_connections_aborted", prometheus.CounterValue,
+		"Total number of backend connections failed because of timeout or network error."}, "1.4"},
+	"server_connections_connected": {metric{"server_connections_connected", prometheus.GaugeValue,
+		"Current number of backend connections."}, "1.4"},
+	"server_connections_created": {metric{"server_connections_created", prometheus.CounterValue,
+		"Total number of backend connections created so far."}, "1.4"},
+	"servers_table_version": {metric{"servers_table_version", prometheus.GaugeValue,
+		"Current version of the in-memory table of backends, incremented on every change."}, "1.4"},
+	"slow_queries": {metric{"slow_queries", prometheus.CounterValue,
+		"Total number of queries that ran for longer than the threshold in milliseconds defined in global variable mysql-long_query_time."}, "1.4"},
+	"sqlite3_memory_bytes": {metric{"sqlite3_memory_bytes", prometheus.GaugeValue,
+		"Current memory used by the embedded SQLite in bytes."}, "1.4"},
+	"stmt_cached": {metric{"stmt_cached", prometheus.GaugeValue,
+		"Current number of prepared statements in the global cache."}, "1.4"},
+	"stmt_client_active_total": {metric{"stmt_client_active_total", prometheus.GaugeValue,
+		"Current number of prepared statements in use by frontends."}, "1.4"},
+	"stmt_client_active_unique": {metric{"stmt_client_active_unique", prometheus.GaugeValue,
+		"Current number of unique prepared statements in use by frontends."}, "1.4"},
+	"stmt_max_stmt_id": {metric{"stmt_max_stmt_id", prometheus.GaugeValue,
+		"The maximum global prepared statement ID ever used."}, "1.4"},
+	"stmt_server_active_total": {metric{"stmt_server_active_total", prometheus.GaugeValue,
+		"Current number of prepared statements in use on backends."}, "1.4"},
+	"stmt_server_active_unique": {metric{"stmt_server_active_unique", prometheus.GaugeValue,
+		"Current number of unique prepared statements in use on backends."}, "1.4"},
+
+	"automatic_detected_sql_injection": {metric{"automatic_detected_sql_injection", prometheus.CounterValue,
+		"Total number of queries blocked as SQL injection attempts."}, "2.0"},
+	"aws_aurora_replicas_skipped_during_query": {metric{"aws_aurora_replicas_skipped_during_query", prometheus.CounterValue,
+		"Total number of times an Aurora replica was skipped because of replication lag."}, "2.0"},
+	"client_connections_hostgroup_locked": {metric{"client_connections_hostgroup_locked", prometheus.GaugeValue,
+		"Current number of frontend connections locked to a hostgroup."}, "2.0"},
+	"client_connections_sha2cached": {metric{"client_connections_sha2cached", prometheus.CounterValue,
+		"Total number of frontend connections authenticated using cached caching_sha2_password credentials."}, "2.0"},
+	"connpool_get_conn_latency_awareness": {metric{"connpool_get_conn_latency_awareness", prometheus.CounterValue,
+		"Total number of connections selected from the connection pool using latency awareness."}, "2.0"},
+	"gtid_consistent_queries": {metric{"gtid_consistent_queries", prometheus.CounterValue,
+		"Total number of queries routed using GTID causal reads."}, "2.0"},
+	"gtid_session_collected": {metric{"gtid_session_collected", prometheus.CounterValue,
+		"Total number of GTIDs collected from backends for frontend sessions."}, "2.0"},
+	"mysql_monitor_connect_check_err": {metric{"mysql_monitor_connect_check_err", prometheus.CounterValue,
+		"Total number of failed Monitor connect checks."}, "2.0"},
+	"mysql_monitor_connect_check_ok": {metric{"mysql_monitor_connect_check_ok", prometheus.CounterValue,
+		"Total number of successful Monitor connect checks."}, "2.0"},
+	"mysql_monitor_ping_check_err": {metric{"mysql_monitor_ping_check_err", prometheus.CounterValue,
+		"Total number of failed Monitor ping checks."}, "2.0"},
+	"mysql_monitor_ping_check_ok": {metric{"mysql_monitor_ping_check_ok", prometheus.CounterValue,
+		"Total number of successful Monitor ping checks."}, "2.0"},
+	"mysql_monitor_read_only_check_err": {metric{"mysql_monitor_read_only_check_err", prometheus.CounterValue,
+		"Total number of failed Monitor read_only checks."}, "2.0"},
+	"mysql_monitor_read_only_check_ok": {metric{"mysql_monitor_read_only_check_ok", prometheus.CounterValue,
+		"Total number of successful Monitor read_only checks."}, "2.0"},
+	"mysql_monitor_replication_lag_check_err": {metric{"mysql_monitor_replication_lag_check_err", prometheus.CounterValue,
+		"Total number of failed Monitor replication lag checks."}, "2.0"},
+	"mysql_monitor_replication_lag_check_ok": {metric{"mysql_monitor_replication_lag_check_ok", prometheus.CounterValue,
+		"Total number of successful Monitor replication lag checks."}, "2.0"},
+	"mysql_unexpected_frontend_com_quit": {metric{"mysql_unexpected_frontend_com_quit", prometheus.CounterValue,
+		"Total number of unexpected COM_QUIT commands received from frontends."}, "2.0"},
+	"mysql_unexpected_frontend_packets": {metric{"mysql_unexpected_frontend_packets", prometheus.CounterValue,
+		"Total number of unexpected packets received from frontends."}, "2.0"},
+	"server_connections_delayed": {metric{"server_connections_delayed", prometheus.CounterValue,
+		"Total number of backend connections delayed because of mysql-throttle_connections_per_sec_to_hostgroup."}, "2.0"},
+	"whitelisted_sqli_fingerprint": {metric{"whitelisted_sqli_fingerprint", prometheus.CounterValue,
+		"Total number of queries detected as SQL injection but allowed by the whitelist of fingerprints."}, "2.0"},
+
+	"mysql_monitor_dns_cache_lookup_success": {metric{"mysql_monitor_dns_cache_lookup_success", prometheus.CounterValue,
+		"Total number of backend hostnames resolved from Monitor DNS cache."}, "2.4"},
+	"mysql_monitor_dns_cache_queried": {metric{"mysql_monitor_dns_cache_queried", prometheus.CounterValue,
+		"Total number of lookups in Monitor DNS cache."}, "2.4"},
+	"mysql_monitor_dns_cache_record_updated": {metric{"mysql_monitor_dns_cache_record_updated", prometheus.CounterValue,
+		"Total number of Monitor DNS cache records updated."}, "2.4"},
+}
+
+// https://github.com/sysown/proxysql/wiki/Query-Cache
+// key - variable name in lowercase.
+var queryCacheMetrics = map[string]*mySQLGlobalMetric{
+	"query_cache_memory_bytes": {metric{"memory_bytes", prometheus.GaugeValue,
+		"Current memory usage of the query cache in bytes."}, "1.4"},
+	"query_cache_entries": {metric{"entries", prometheus.GaugeValue,
+		"Current number of entries in the query cache."}, "1.4"},
+	"query_cache_count_get": {metric{"count_get", prometheus.CounterValue,
+		"Total number of read requests to the query cache."}, "1.4"},
+	"query_cache_count_get_ok": {metric{"count_get_ok", prometheus.CounterValue,
+		"Total number of successful read requests to the query cache (cache hits)."}, "1.4"},
+	"query_cache_count_set": {metric{"count_set", prometheus.CounterValue,
+		"Total number of write requests to the query cache."}, "1.4"},
+	"query_cache_bytes_in": {metric{"bytes_in", prometheus.CounterValue,
+		"Total number of bytes written to the query cache."}, "1.4"},
+	"query_cache_bytes_out": {metric{"bytes_out", prometheus.CounterValue,
+		"Total number of bytes read from the query cache."}, "1.4"},
+	"query_cache_purged": {metric{"purged", prometheus.CounterValue,
+		"Total number of entries purged from the query cache because of expiration."}, "1.4"},
+	"hit_ratio": {metric{"hit_ratio", prometheus.GaugeValue,
+		"The ratio of successful read requests to all read requests to the query cache since ProxySQL start."}, "1.4"},
+}
+
+// mySQLGlobalUnit returns a divisor to convert documented variable value to seconds.
+func mySQLGlobalUnit(name string) float64 {
+	switch {
+	case strings.HasSuffix(name, "_nsec"):
+		return 1e9
+	case strings.HasSuffix(name, "_us"):
+		return 1e6
+	default:
+		return 1
+	}
+}
+
+// key - variable name in lowercase.
+var threadsMetrics = map[string]*mySQLGlobalMetric{
+	"mysql_thread_workers": {metric{"mysql_workers", prometheus.GaugeValue,
+		"Current number of MySQL worker threads."}, "1.4"},
+	"mysql_monitor_workers": {metric{"monitor_workers", prometheus.GaugeValue,
+		"Current number of Monitor worker threads."}, "1.4"},
+	"mysql_monitor_workers_aux": {metric{"monitor_workers_aux", prometheus.GaugeValue,
+		"Current number of auxiliary Monitor threads."}, "1.4"},
+	"mysql_monitor_workers_started": {metric{"monitor_workers_started", prometheus.CounterValue,
+		"Total number of Monitor worker threads started."}, "1.4"},
+}
+
+// key - variable name in lowercase.
+var buffersMetrics = map[string]*mySQLGlobalMetric{
+	"mysql_frontend_buffers_bytes": {metric{"bytes", prometheus.GaugeValue,
+		"Current memory used by buffers of connections in bytes."}, "1.4"},
+	"mysql_backend_buffers_bytes": {metric{"bytes", prometheus.GaugeValue,
+		"Current memory used by buffers of connections in bytes."}, "1.4"},
+	"mysql_session_internal_bytes": {metric{"session_internal_bytes", prometheus.GaugeValue,
+		"Current memory used by internal structures of MySQL sessions in bytes."}, "1.4"},
+}
+
+// mySQLGlobalFamily is a group of `stats_mysql_global` variables exposed under own subsystem.
+type mySQLGlobalFamily struct {
+	subsystem string
+	metrics   map[string]*mySQLGlobalMetric // key - variable name in lowercase
+	labels    map[string]prometheus.Labels  // key - variable name in lowercase, value - constant labels
+}
+
+var mySQLGlobalFamilies = []mySQLGlobalFamily{
+	{subsystem: "query_cache", metrics: queryCacheMetrics},
+	{subsystem: "threads", metrics: threadsMetrics},
+	{
+		subsystem: "buffers",
+		metrics:   buffersMetrics,
+		labels: map[string]prometheus.Labels{
+			"mysql_frontend_buffers_bytes": {"side": "frontend"},
+			"mysql_backend_buffers_bytes":  {"side": "backend"},
+		},
+	},
 }
 
 // scrapeMySQLGlobal collects metrics from `stats_mysql_global`.
+// Query cache, threads and buffers variables are exposed as separate families,
+// query cache one together with derived hit ratio.
 func scrapeMySQLGlobal(db *sql.DB, ch chan<- prometheus.Metric) error {
 	rows, err := db.Query(mySQLGlobalQuery)
 	if err != nil {
@@ -236,6 +685,7 @@ func scrapeMySQLGlobal(db *sql.DB, ch chan<- prometheus.Metric) error {
 	defer rows.Close()
 
 	var name, valueS string
+	familyValues := make(map[string]float64)
 	for rows.Next() {
 		if err = rows.Scan(&name, &valueS); err != nil {
 			return err
@@ -247,8 +697,16 @@ func scrapeMySQLGlobal(db *sql.DB, ch chan<- prometheus.Metric) error {
 		}
 
 		name = strings.ToLower(name)
-		m := mySQLGlobalMetrics[name]
-		if m == nil {
+		if scrapeMySQLGlobalFamilies(ch, name, value) {
+			familyValues[name] = value
+			continue
+		}
+
+		var m *metric
+		if gm := mySQLGlobalMetrics[name]; gm != nil {
+			m = &gm.metric
+			value /= mySQLGlobalUnit(name)
+		} else {
 			m = &metric{
 				name:      name,
 				valueType: prometheus.UntypedValue,
@@ -264,7 +722,43 @@ func scrapeMySQLGlobal(db *sql.DB, ch chan<- prometheus.Metric) error {
 			m.valueType, value,
 		)
 	}
-	return rows.Err()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// ratio is undefined if query cache was never read
+	if gets := familyValues["query_cache_count_get"]; gets > 0 {
+		m := queryCacheMetrics["hit_ratio"]
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "query_cache", m.name),
+				m.help,
+				nil, nil,
+			),
+			m.valueType, familyValues["query_cache_count_get_ok"]/gets,
+		)
+	}
+	return nil
+}
+
+// scrapeMySQLGlobalFamilies sends variable from one of mySQLGlobalFamilies, and returns false if it is not there.
+func scrapeMySQLGlobalFamilies(ch chan<- prometheus.Metric, name string, value float64) bool {
+	for _, f := range mySQLGlobalFamilies {
+		m := f.metrics[name]
+		if m == nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, f.subsystem, m.name),
+				m.help,
+				nil, f.labels[name],
+			),
+			m.valueType, value,
+		)
+		return true
+	}
+	return false
 }
 
 const mySQLconnectionPoolQuery = "SELECT hostgroup, srv_host, srv_port, * FROM stats_mysql_connection_pool"
@@ -376,104 +870,1914 @@ func scrapeMySQLConnectionPool(db *sql.DB, ch chan<- prometheus.Metric) error {
 	return rows.Err()
 }
 
-const mySQLConnectionListQuery = "SELECT COUNT(cli_host) as connection_count, cli_host FROM stats_mysql_processlist GROUP BY cli_host"
+const (
+	queryDigestOrderBySumTime   = "sum_time"
+	queryDigestOrderByCountStar = "count_star"
+)
 
-var mySQLconnectionListMetrics = map[string]*metric{
-	"connection_count": {"client_connection_list", prometheus.GaugeValue,
-		"Total number of frontend connections"},
+// Digests may be split by client_address in ProxySQL 2.x, so we group them back.
+const mySQLQueryDigestQuery = `SELECT hostgroup, schemaname, username, digest,
+	SUM(count_star) AS count_star, SUM(sum_time) AS sum_time, MIN(min_time) AS min_time, MAX(max_time) AS max_time,
+	SUM(sum_rows_affected) AS rows_affected, SUM(sum_rows_sent) AS rows_sent
+	FROM stats_mysql_query_digest GROUP BY hostgroup, schemaname, username, digest ORDER BY %s DESC`
+
+// https://github.com/sysown/proxysql/wiki/STATS-(statistics)#stats_mysql_query_digest
+// Order matches value columns in mySQLQueryDigestQuery.
+var mySQLQueryDigestColumns = []string{"count_star", "sum_time", "min_time", "max_time", "rows_affected", "rows_sent"}
+
+// key - column name in lowercase.
+var mySQLQueryDigestMetrics = map[string]*metric{
+	"count_star": {"count_star", prometheus.CounterValue,
+		"The total number of times the query has been executed."},
+	"sum_time": {"sum_time_us", prometheus.CounterValue,
+		"The total time in microseconds spent executing queries of this type."},
+	"min_time": {"min_time_us", prometheus.GaugeValue,
+		"The minimal duration in microseconds of queries of this type."},
+	"max_time": {"max_time_us", prometheus.GaugeValue,
+		"The maximal duration in microseconds of queries of this type."},
+	"rows_affected": {"rows_affected", prometheus.CounterValue,
+		"The total number of rows affected by queries of this type."},
+	"rows_sent": {"rows_sent", prometheus.CounterValue,
+		"The total number of rows sent by queries of this type."},
 }
 
-// scrapeMySQLConnectionList collects connection list from `stats_mysql_processlist`.
-func scrapeMySQLConnectionList(db *sql.DB, ch chan<- prometheus.Metric) error {
-	rows, err := db.Query(mySQLConnectionListQuery)
+type queryDigestResult struct {
+	hostgroup, schemaName, username, digest string
+	values                                  []float64
+}
+
+// add folds values of other result into r.
+func (r *queryDigestResult) add(other *queryDigestResult) {
+	for i, column := range mySQLQueryDigestColumns {
+		switch column {
+		case "min_time":
+			if other.values[i] < r.values[i] {
+				r.values[i] = other.values[i]
+			}
+		case "max_time":
+			if other.values[i] > r.values[i] {
+				r.values[i] = other.values[i]
+			}
+		default:
+			r.values[i] += other.values[i]
+		}
+	}
+}
+
+// scrapeMySQLQueryDigest collects metrics from `stats_mysql_query_digest`.
+// Only top limit digests ordered by orderBy column are exported as is,
+// everything else is folded into proxysql_query_digest_other_* gauges.
+// They are gauges, not counters, because they drop whenever a digest enters top.
+func scrapeMySQLQueryDigest(db *sql.DB, ch chan<- prometheus.Metric, limit int, orderBy string) error {
+	switch orderBy {
+	case queryDigestOrderBySumTime, queryDigestOrderByCountStar:
+	default:
+		return fmt.Errorf("unexpected stats_mysql_query_digest order column %q", orderBy)
+	}
+
+	rows, err := db.Query(fmt.Sprintf(mySQLQueryDigestQuery, orderBy))
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
-	columns, err := rows.Columns()
-	if err != nil {
+	var results []*queryDigestResult
+	var other *queryDigestResult
+	for rows.Next() {
+		res := &queryDigestResult{values: make([]float64, len(mySQLQueryDigestColumns))}
+		scan := []interface{}{&res.hostgroup, &res.schemaName, &res.username, &res.digest}
+		for i := range res.values {
+			scan = append(scan, &res.values[i])
+		}
+		if err = rows.Scan(scan...); err != nil {
+			return err
+		}
+
+		if len(results) < limit {
+			results = append(results, res)
+			continue
+		}
+		if other == nil {
+			other = res
+			continue
+		}
+		other.add(res)
+	}
+	if err = rows.Err(); err != nil {
 		return err
 	}
 
-	scan := make([]interface{}, len(columns))
-	var cliHost string
-	var connNum float64
+	for _, res := range results {
+		for i, column := range mySQLQueryDigestColumns {
+			m := mySQLQueryDigestMetrics[column]
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "query_digest", m.name),
+					m.help,
+					[]string{"hostgroup", "schemaname", "username", "digest"}, nil,
+				),
+				m.valueType, res.values[i],
+				res.hostgroup, res.schemaName, res.username, res.digest,
+			)
+		}
+	}
+	if other != nil {
+		for i, column := range mySQLQueryDigestColumns {
+			m := mySQLQueryDigestMetrics[column]
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "query_digest_other", m.name),
+					m.help+" Sum for queries outside of top digests, not monotonic.",
+					nil, nil,
+				),
+				prometheus.GaugeValue, other.values[i],
+			)
+		}
+	}
+	return nil
+}
 
-	scan[0], scan[1] = &connNum, &cliHost
+const mySQLCommandsCountersQuery = `SELECT Command, Total_Time_us, Total_cnt,
+	cnt_100us, cnt_500us, cnt_1ms, cnt_5ms, cnt_10ms, cnt_50ms, cnt_100ms, cnt_500ms, cnt_1s, cnt_5s, cnt_10s, cnt_INFs
+	FROM stats_mysql_commands_counters`
+
+// Upper bounds in seconds of cnt_* columns in mySQLCommandsCountersQuery, except the last cnt_INFs one.
+var mySQLCommandsCountersBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// https://github.com/sysown/proxysql/wiki/STATS-(statistics)#stats_mysql_commands_counters
+// key - column name in lowercase.
+var mySQLCommandsCountersMetrics = map[string]*metric{
+	"total_time_us": {"total_time_us", prometheus.CounterValue,
+		"The total time in microseconds spent executing commands of that type."},
+	"total_cnt": {"total_cnt", prometheus.CounterValue,
+		"The total number of commands of that type executed."},
+}
+
+var mySQLCommandsLatencyDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "commands", "latency_seconds"),
+	"Histogram of commands execution time in seconds.",
+	[]string{"command"}, nil,
+)
+
+// Order matches Total_* columns in mySQLCommandsCountersQuery.
+var mySQLCommandsCountersColumns = []string{"total_time_us", "total_cnt"}
+
+type commandsCountersResult struct {
+	command string
+	totals  []float64
+	counts  []uint64
+}
+
+// scrapeMySQLCommandsCounters collects metrics from `stats_mysql_commands_counters`.
+func scrapeMySQLCommandsCounters(db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.Query(mySQLCommandsCountersQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
 	for rows.Next() {
+		// one more column for cnt_INFs
+		res := commandsCountersResult{
+			totals: make([]float64, len(mySQLCommandsCountersColumns)),
+			counts: make([]uint64, len(mySQLCommandsCountersBuckets)+1),
+		}
+		scan := []interface{}{&res.command}
+		for i := range res.totals {
+			scan = append(scan, &res.totals[i])
+		}
+		for i := range res.counts {
+			scan = append(scan, &res.counts[i])
+		}
 		if err = rows.Scan(scan...); err != nil {
 			return err
 		}
 
-		column := strings.ToLower(columns[0])
+		for i, column := range mySQLCommandsCountersColumns {
+			m := mySQLCommandsCountersMetrics[column]
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "commands", m.name),
+					m.help,
+					[]string{"command"}, nil,
+				),
+				m.valueType, res.totals[i],
+				res.command,
+			)
+		}
 
-		m := mySQLconnectionListMetrics[column]
-		if m == nil {
-			m = &metric{
-				name:      "client_connection_list",
-				valueType: prometheus.UntypedValue,
-				help:      "Undocumented stats_mysql_processlist metric.",
-			}
+		// ProxySQL counts every command in a single bucket, Prometheus buckets are cumulative
+		var count uint64
+		buckets := make(map[float64]uint64, len(mySQLCommandsCountersBuckets))
+		for i, le := range mySQLCommandsCountersBuckets {
+			count += res.counts[i]
+			buckets[le] = count
 		}
+		count += res.counts[len(mySQLCommandsCountersBuckets)]
 
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				prometheus.BuildFQName(namespace, "processlist", m.name),
-				m.help,
-				[]string{"client_host"}, nil,
-			),
-			m.valueType, connNum,
-			cliHost,
+		ch <- prometheus.MustNewConstHistogram(
+			mySQLCommandsLatencyDesc,
+			count, res.totals[0]/1e6, buckets, // Total_Time_us
+			res.command,
 		)
 	}
 	return rows.Err()
 }
 
-const detailedMySQLProcessListQuery = "SELECT user, db, cli_host, hostgroup, COUNT(*) as count from stats_mysql_processlist group by user, db, cli_host, hostgroup"
+// Errors may be split by client_address, so we group them back.
+const mySQLErrorsQuery = `SELECT hostgroup, hostname, port, username, schemaname, errno,
+	SUM(count_star) AS count_star, MIN(first_seen) AS first_seen, MAX(last_seen) AS last_seen
+	FROM stats_mysql_errors GROUP BY hostgroup, hostname, port, username, schemaname, errno`
 
-var detailedMySQLProcessListMetrics = map[string]*metric{
-	"detailed_connection_count": {name: "detailed_client_connection_count", valueType: prometheus.GaugeValue, help: "Number of client connections per user, db, host and hostgroup."},
+// https://github.com/sysown/proxysql/wiki/STATS-(statistics)#stats_mysql_errors
+// Order matches value columns in mySQLErrorsQuery.
+var mySQLErrorsColumns = []string{"count_star", "first_seen", "last_seen"}
+
+// key - column name in lowercase.
+var mySQLErrorsMetrics = map[string]*metric{
+	"count_star": {"count_star", prometheus.CounterValue,
+		"The number of times this error was returned by the backend server."},
+	"first_seen": {"first_seen", prometheus.GaugeValue,
+		"The time when this error was first seen, as Unix timestamp."},
+	"last_seen": {"last_seen", prometheus.GaugeValue,
+		"The time when this error was last seen, as Unix timestamp."},
 }
 
-type processListResult struct {
-	user, db, clientHost, hostGroup string
-	count                           float64
+type mySQLErrorsResult struct {
+	hostgroup, hostname, port, username, schemaName, errno string
+	values                                                 []float64
 }
 
-func scrapeDetailedMySQLConnectionList(db *sql.DB, ch chan<- prometheus.Metric) error {
-	rows, err := db.Query(detailedMySQLProcessListQuery)
+// scrapeMySQLErrors collects metrics from `stats_mysql_errors`.
+func scrapeMySQLErrors(db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.Query(mySQLErrorsQuery)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
-
 	for rows.Next() {
-		var res processListResult
-
-		err := rows.Scan(&res.user, &res.db, &res.clientHost, &res.hostGroup, &res.count)
-		if err != nil {
+		res := mySQLErrorsResult{values: make([]float64, len(mySQLErrorsColumns))}
+		scan := []interface{}{&res.hostgroup, &res.hostname, &res.port, &res.username, &res.schemaName, &res.errno}
+		for i := range res.values {
+			scan = append(scan, &res.values[i])
+		}
+		if err = rows.Scan(scan...); err != nil {
 			return err
 		}
 
-		m := detailedMySQLProcessListMetrics["detailed_connection_count"]
+		for i, column := range mySQLErrorsColumns {
+			m := mySQLErrorsMetrics[column]
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "mysql_errors", m.name),
+					m.help,
+					[]string{"hostgroup", "endpoint", "username", "schemaname", "errno"}, nil,
+				),
+				m.valueType, res.values[i],
+				res.hostgroup, res.hostname+":"+res.port, res.username, res.schemaName, res.errno,
+			)
+		}
+	}
+	return rows.Err()
+}
 
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				prometheus.BuildFQName(namespace, "processlist", m.name),
-				m.help,
-				[]string{"user", "db", "client_host", "hostgroup"},
-				nil,
-			),
-			m.valueType,
-			res.count,
-			res.user, res.db, res.clientHost, res.hostGroup,
-		)
+// monitorLog describes a log table in ProxySQL's `monitor` schema.
+type monitorLog struct {
+	name        string // used as metric name prefix and log label value
+	table       string
+	errorColumn string
+	columns     []string           // value columns
+	metrics     map[string]*metric // key - column name in lowercase
+}
+
+// https://github.com/sysown/proxysql/wiki/Monitor-Module#monitor-tables
+var monitorLogs = []monitorLog{
+	{
+		name:        "ping",
+		table:       "mysql_server_ping_log",
+		errorColumn: "ping_error",
+		columns:     []string{"ping_success_time_us"},
+		metrics: map[string]*metric{
+			"ping_success_time_us": {"ping_latency_us", prometheus.GaugeValue,
+				"The latency in microseconds of the last ping check, as reported from Monitor."},
+		},
+	},
+	{
+		name:        "connect",
+		table:       "mysql_server_connect_log",
+		errorColumn: "connect_error",
+		columns:     []string{"connect_success_time_us"},
+		metrics: map[string]*metric{
+			"connect_success_time_us": {"connect_latency_us", prometheus.GaugeValue,
+				"The latency in microseconds of the last connect check, as reported from Monitor."},
+		},
+	},
+	{
+		name:        "read_only",
+		table:       "mysql_server_read_only_log",
+		errorColumn: "error",
+		columns:     []string{"success_time_us", "read_only"},
+		metrics: map[string]*metric{
+			"success_time_us": {"read_only_latency_us", prometheus.GaugeValue,
+				"The latency in microseconds of the last read_only check, as reported from Monitor."},
+			"read_only": {"read_only", prometheus.GaugeValue,
+				"The value of read_only variable on the last read_only check, as reported from Monitor."},
+		},
+	},
+	{
+		name:        "replication_lag",
+		table:       "mysql_server_replication_lag_log",
+		errorColumn: "error",
+		columns:     []string{"success_time_us", "repl_lag"},
+		metrics: map[string]*metric{
+			"success_time_us": {"replication_lag_latency_us", prometheus.GaugeValue,
+				"The latency in microseconds of the last replication lag check, as reported from Monitor."},
+			"repl_lag": {"replication_lag_seconds", prometheus.GaugeValue,
+				"The replication lag in seconds on the last replication lag check, as reported from Monitor."},
+		},
+	},
+}
+
+// SQLite takes bare columns from the row with MAX(time_start_us).
+const monitorLogLastQuery = "SELECT hostname, port, MAX(time_start_us), %s, %s FROM monitor.%s GROUP BY hostname, port"
+
+const monitorLogErrorsQuery = "SELECT hostname, port, time_start_us FROM monitor.%s WHERE %s IS NOT NULL AND %s != '' AND time_start_us > %d ORDER BY time_start_us"
+
+// monitorErrors counts Monitor errors between scrapes.
+type monitorErrors struct {
+	total *prometheus.CounterVec
+
+	// held across query and update of since, so concurrent scrapes do not count the same errors twice
+	m     sync.Mutex
+	since map[monitorErrorsKey]int64 // value - time_start_us of the last counted error
+}
+
+// monitorErrorsKey identifies backend server in Monitor log. Checks of different servers run in parallel
+// and slow ones are logged after faster ones started later, so the last counted error is tracked per server.
+type monitorErrorsKey struct {
+	log      string
+	endpoint string
+}
+
+func newMonitorErrors() *monitorErrors {
+	return &monitorErrors{
+		total: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "monitor",
+			Name:      "errors_total",
+			Help:      "Total number of errors seen in Monitor logs.",
+		}, []string{"log", "endpoint"}),
+		since: make(map[monitorErrorsKey]int64),
 	}
+}
 
-	return rows.Err()
+// scrapeMonitor collects the last sample per backend server from Monitor logs in `monitor` schema,
+// and counts Monitor errors which appeared since the previous scrape.
+func scrapeMonitor(db *sql.DB, ch chan<- prometheus.Metric, errs *monitorErrors) error {
+	for _, l := range monitorLogs {
+		if err := scrapeMonitorLogLast(db, ch, l); err != nil {
+			return err
+		}
+		if err := scrapeMonitorLogErrors(db, l, errs); err != nil {
+			return err
+		}
+	}
+	errs.total.Collect(ch)
+	return nil
+}
+
+func scrapeMonitorLogLast(db *sql.DB, ch chan<- prometheus.Metric, l monitorLog) error {
+	rows, err := db.Query(fmt.Sprintf(monitorLogLastQuery, strings.Join(l.columns, ", "), l.errorColumn, l.table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hostname, port string
+		var timeStart int64
+		var errorS sql.NullString
+		values := make([]sql.NullFloat64, len(l.columns))
+		scan := []interface{}{&hostname, &port, &timeStart}
+		for i := range values {
+			scan = append(scan, &values[i])
+		}
+		scan = append(scan, &errorS)
+		if err = rows.Scan(scan...); err != nil {
+			return err
+		}
+
+		endpoint := hostname + ":" + port
+		for i, column := range l.columns {
+			// there is no value if check failed
+			if !values[i].Valid {
+				continue
+			}
+			m := l.metrics[column]
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "monitor", m.name),
+					m.help,
+					[]string{"endpoint"}, nil,
+				),
+				m.valueType, values[i].Float64,
+				endpoint,
+			)
+		}
+
+		var value float64
+		if errorS.String != "" {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "monitor", l.name+"_error"),
+				"Whether the last "+strings.Replace(l.name, "_", " ", -1)+" check resulted in an error (1 for error, 0 for success).",
+				[]string{"endpoint"}, nil,
+			),
+			prometheus.GaugeValue, value,
+			endpoint,
+		)
+	}
+	return rows.Err()
+}
+
+func scrapeMonitorLogErrors(db *sql.DB, l monitorLog, errs *monitorErrors) error {
+	errs.m.Lock()
+	defer errs.m.Unlock()
+
+	// query errors after the earliest last counted one, they are filtered per server below;
+	// servers without counted errors had none before it, as previous queries would return them
+	var since int64
+	first := true
+	for k, timeStart := range errs.since {
+		if k.log == l.name && (first || timeStart < since) {
+			since = timeStart
+			first = false
+		}
+	}
+
+	rows, err := db.Query(fmt.Sprintf(monitorLogErrorsQuery, l.table, l.errorColumn, l.errorColumn, since))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hostname, port string
+		var timeStart int64
+		if err = rows.Scan(&hostname, &port, &timeStart); err != nil {
+			return err
+		}
+
+		k := monitorErrorsKey{log: l.name, endpoint: hostname + ":" + port}
+		if timeStart <= errs.since[k] {
+			continue
+		}
+		errs.total.WithLabelValues(l.name, k.endpoint).Inc()
+		errs.since[k] = timeStart
+	}
+	return rows.Err()
+}
+
+const runtimeMySQLServersQuery = `SELECT hostgroup_id, hostname, port, status,
+	weight, compression, max_connections, max_replication_lag, use_ssl, max_latency_ms
+	FROM runtime_mysql_servers`
+
+// https://github.com/sysown/proxysql/wiki/Main-(runtime)#mysql_servers
+// Order matches value columns in runtimeMySQLServersQuery.
+var runtimeMySQLServersColumns = []string{"weight", "compression", "max_connections", "max_replication_lag", "use_ssl", "max_latency_ms"}
+
+// key - column name in lowercase.
+var runtimeMySQLServersMetrics = map[string]*metric{
+	"weight": {"weight", prometheus.GaugeValue,
+		"The configured weight of the backend server in its hostgroup."},
+	"compression": {"compression", prometheus.GaugeValue,
+		"Whether connections to the backend server use compression (1 for compression, 0 for none)."},
+	"max_connections": {"max_connections", prometheus.GaugeValue,
+		"The maximum number of connections ProxySQL will open to the backend server."},
+	"max_replication_lag": {"max_replication_lag", prometheus.GaugeValue,
+		"The replication lag in seconds after which the backend server is shunned, 0 if disabled."},
+	"use_ssl": {"use_ssl", prometheus.GaugeValue,
+		"Whether connections to the backend server use SSL (1 for SSL, 0 for none)."},
+	"max_latency_ms": {"max_latency_ms", prometheus.GaugeValue,
+		"The ping time in milliseconds after which the backend server is excluded from the connection pool, 0 if disabled."},
+}
+
+var runtimeMySQLServersInfoDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "runtime_mysql_servers", "info"),
+	"Backend servers configured at runtime, including ones without connection pool entries.",
+	[]string{"hostgroup", "endpoint", "status"}, nil,
+)
+
+type runtimeMySQLServersResult struct {
+	hostgroup, hostname, port, status string
+	values                            []float64
+}
+
+// scrapeRuntimeMySQLServers collects metrics from `runtime_mysql_servers`.
+func scrapeRuntimeMySQLServers(db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.Query(runtimeMySQLServersQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		res := runtimeMySQLServersResult{values: make([]float64, len(runtimeMySQLServersColumns))}
+		scan := []interface{}{&res.hostgroup, &res.hostname, &res.port, &res.status}
+		for i := range res.values {
+			scan = append(scan, &res.values[i])
+		}
+		if err = rows.Scan(scan...); err != nil {
+			return err
+		}
+
+		endpoint := res.hostname + ":" + res.port
+		ch <- prometheus.MustNewConstMetric(
+			runtimeMySQLServersInfoDesc,
+			prometheus.GaugeValue, 1,
+			res.hostgroup, endpoint, res.status,
+		)
+		for i, column := range runtimeMySQLServersColumns {
+			m := runtimeMySQLServersMetrics[column]
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "runtime_mysql_servers", m.name),
+					m.help,
+					[]string{"hostgroup", "endpoint"}, nil,
+				),
+				m.valueType, res.values[i],
+				res.hostgroup, endpoint,
+			)
+		}
+	}
+	return rows.Err()
+}
+
+// hostgroupsTable describes a runtime table which maps hostgroups of a cluster to their roles.
+type hostgroupsTable struct {
+	clusterType string // cluster_type label value
+	table       string
+	roles       []string // hostgroup columns without _hostgroup suffix, the first one is always writer
+	maxWriters  bool     // whether table has max_writers column
+}
+
+// https://github.com/sysown/proxysql/wiki/Main-(runtime)
+var hostgroupsTables = []hostgroupsTable{
+	{
+		clusterType: "replication",
+		table:       "runtime_mysql_replication_hostgroups",
+		roles:       []string{"writer", "reader"},
+	},
+	{
+		clusterType: "group_replication",
+		table:       "runtime_mysql_group_replication_hostgroups",
+		roles:       []string{"writer", "backup_writer", "reader", "offline"},
+		maxWriters:  true,
+	},
+	{
+		clusterType: "galera",
+		table:       "runtime_mysql_galera_hostgroups",
+		roles:       []string{"writer", "backup_writer", "reader", "offline"},
+		maxWriters:  true,
+	},
+	{
+		clusterType: "aws_aurora",
+		table:       "runtime_mysql_aws_aurora_hostgroups",
+		roles:       []string{"writer", "reader"},
+	},
+}
+
+// query returns SELECT statement for hostgroup columns, max_writers if present, and the number of ONLINE writers.
+func (t hostgroupsTable) query() string {
+	columns := make([]string, 0, len(t.roles)+2)
+	for _, role := range t.roles {
+		columns = append(columns, role+"_hostgroup")
+	}
+	if t.maxWriters {
+		columns = append(columns, "max_writers")
+	}
+	columns = append(columns, "(SELECT COUNT(*) FROM runtime_mysql_servers s WHERE s.hostgroup_id = h.writer_hostgroup AND s.status = 'ONLINE') AS writers")
+	return fmt.Sprintf("SELECT %s FROM %s h", strings.Join(columns, ", "), t.table)
+}
+
+var (
+	hostgroupsRoleInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "hostgroups", "role_info"),
+		"The role of the hostgroup in the cluster identified by its writer hostgroup.",
+		[]string{"hostgroup", "role", "cluster_type", "writer_hostgroup"}, nil,
+	)
+	hostgroupsWritersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "hostgroups", "writers"),
+		"The number of ONLINE backend servers in the writer hostgroup of the cluster.",
+		[]string{"cluster_type", "writer_hostgroup"}, nil,
+	)
+	hostgroupsMaxWritersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "hostgroups", "max_writers"),
+		"The maximum number of backend servers allowed in the writer hostgroup of the cluster.",
+		[]string{"cluster_type", "writer_hostgroup"}, nil,
+	)
+)
+
+// scrapeHostgroups collects hostgroup roles from replication, group replication, Galera and Aurora runtime tables.
+// Tables missing in the current ProxySQL version are skipped.
+func scrapeHostgroups(db *sql.DB, ch chan<- prometheus.Metric) error {
+	for _, t := range hostgroupsTables {
+		if err := scrapeHostgroupsTable(db, ch, t); err != nil {
+			if strings.Contains(err.Error(), "no such table") {
+				log.Debugf("table %s: %s", t.table, err)
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func scrapeHostgroupsTable(db *sql.DB, ch chan<- prometheus.Metric, t hostgroupsTable) error {
+	rows, err := db.Query(t.query())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		hostgroups := make([]string, len(t.roles))
+		var maxWriters, writers float64
+		scan := make([]interface{}, 0, len(t.roles)+2)
+		for i := range hostgroups {
+			scan = append(scan, &hostgroups[i])
+		}
+		if t.maxWriters {
+			scan = append(scan, &maxWriters)
+		}
+		scan = append(scan, &writers)
+		if err = rows.Scan(scan...); err != nil {
+			return err
+		}
+
+		writerHostgroup := hostgroups[0]
+		for i, role := range t.roles {
+			ch <- prometheus.MustNewConstMetric(
+				hostgroupsRoleInfoDesc,
+				prometheus.GaugeValue, 1,
+				hostgroups[i], role, t.clusterType, writerHostgroup,
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			hostgroupsWritersDesc,
+			prometheus.GaugeValue, writers,
+			t.clusterType, writerHostgroup,
+		)
+		if t.maxWriters {
+			ch <- prometheus.MustNewConstMetric(
+				hostgroupsMaxWritersDesc,
+				prometheus.GaugeValue, maxWriters,
+				t.clusterType, writerHostgroup,
+			)
+		}
+	}
+	return rows.Err()
+}
+
+const (
+	proxySQLServersStatusQuery    = "SELECT hostname, port, * FROM stats_proxysql_servers_status"
+	proxySQLServersMetricsQuery   = "SELECT hostname, port, * FROM stats_proxysql_servers_metrics"
+	proxySQLServersChecksumsQuery = "SELECT hostname, port, name, version, epoch, checksum, diff_check FROM stats_proxysql_servers_checksums"
+)
+
+// https://github.com/sysown/proxysql/wiki/ProxySQL-Cluster#stats-tables
+// key - column name in lowercase.
+var proxySQLServersStatusMetrics = map[string]*metric{
+	"weight": {"weight", prometheus.GaugeValue,
+		"The configured weight of the peer."},
+	"global_version": {"global_version", prometheus.GaugeValue,
+		"The global version of the peer configuration."},
+	"check_age_us": {"check_age_us", prometheus.GaugeValue,
+		"The time in microseconds since the last check of the peer."},
+	"ping_time_us": {"ping_time_us", prometheus.GaugeValue,
+		"The ping time in microseconds of the peer."},
+	"checks_ok": {"checks_ok", prometheus.CounterValue,
+		"How many checks of the peer were successful."},
+	"checks_err": {"checks_err", prometheus.CounterValue,
+		"How many checks of the peer failed."},
+}
+
+// key - column name in lowercase.
+var proxySQLServersMetricsMetrics = map[string]*metric{
+	"weight": {"weight", prometheus.GaugeValue,
+		"The configured weight of the peer."},
+	"response_time_ms": {"response_time_ms", prometheus.GaugeValue,
+		"The time in milliseconds the peer took to respond to the last metrics request."},
+	"uptime_s": {"uptime_s", prometheus.CounterValue,
+		"The peer uptime in seconds."},
+	"last_check_ms": {"last_check_ms", prometheus.GaugeValue,
+		"The time in milliseconds since the last metrics check of the peer."},
+	"queries": {"queries", prometheus.CounterValue,
+		"The total number of queries executed by the peer."},
+	"client_connections_connected": {"client_connections_connected", prometheus.GaugeValue,
+		"The current number of frontend connections of the peer."},
+	"client_connections_created": {"client_connections_created", prometheus.CounterValue,
+		"The total number of frontend connections created by the peer."},
+}
+
+var (
+	proxySQLServersChecksumsVersionDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cluster_checksums", "version"),
+		"The version of the module configuration on the peer.",
+		[]string{"peer", "module"}, nil,
+	)
+	proxySQLServersChecksumsEpochDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cluster_checksums", "epoch"),
+		"The time when the module configuration was created on the peer, as Unix timestamp.",
+		[]string{"peer", "module"}, nil,
+	)
+	proxySQLServersChecksumsDiffCheckDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cluster_checksums", "diff_check"),
+		"How many checks in a row found the module configuration on the peer different from the local one.",
+		[]string{"peer", "module"}, nil,
+	)
+	proxySQLServersChecksumsDistinctDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cluster_checksums", "distinct"),
+		"The number of distinct module configuration checksums across peers, 1 if all peers are in sync.",
+		[]string{"module"}, nil,
+	)
+)
+
+// scrapeProxySQLServers collects ProxySQL Cluster peers metrics from `stats_proxysql_servers_status`,
+// `stats_proxysql_servers_metrics` and `stats_proxysql_servers_checksums`.
+func scrapeProxySQLServers(db *sql.DB, ch chan<- prometheus.Metric) error {
+	if err := scrapeProxySQLServersTable(db, ch, proxySQLServersStatusQuery, "cluster_status", proxySQLServersStatusMetrics); err != nil {
+		return err
+	}
+	if err := scrapeProxySQLServersTable(db, ch, proxySQLServersMetricsQuery, "cluster_metrics", proxySQLServersMetricsMetrics); err != nil {
+		return err
+	}
+	return scrapeProxySQLServersChecksums(db, ch)
+}
+
+// scrapeProxySQLServersTable collects all numeric columns of given stats_proxysql_servers_* table.
+func scrapeProxySQLServersTable(db *sql.DB, ch chan<- prometheus.Metric, query, subsystem string, metrics map[string]*metric) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	// first 2 columns are fixed in our SELECT statement
+	scan := make([]interface{}, len(columns))
+	var hostname, port string
+	scan[0], scan[1] = &hostname, &port
+	for i := 2; i < len(scan); i++ {
+		scan[i] = new(sql.NullString)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(scan...); err != nil {
+			return err
+		}
+
+		for i := 2; i < len(columns); i++ {
+			column := strings.ToLower(columns[i])
+			switch column {
+			case "hostname", "port", "comment":
+				continue
+			}
+
+			value, err := strconv.ParseFloat(scan[i].(*sql.NullString).String, 64)
+			if err != nil {
+				log.Debugf("column %s: %s", column, err)
+				continue
+			}
+
+			m := metrics[column]
+			if m == nil {
+				m = &metric{
+					name:      column,
+					valueType: prometheus.UntypedValue,
+					help:      "Undocumented stats_proxysql_servers metric.",
+				}
+			}
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, subsystem, m.name),
+					m.help,
+					[]string{"peer"}, nil,
+				),
+				m.valueType, value,
+				hostname+":"+port,
+			)
+		}
+	}
+	return rows.Err()
+}
+
+func scrapeProxySQLServersChecksums(db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.Query(proxySQLServersChecksumsQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var modules []string
+	checksums := make(map[string]map[string]struct{}) // module -> set of checksums
+	for rows.Next() {
+		var hostname, port, module string
+		var version, epoch, diffCheck float64
+		var checksum sql.NullString
+		if err = rows.Scan(&hostname, &port, &module, &version, &epoch, &checksum, &diffCheck); err != nil {
+			return err
+		}
+
+		peer := hostname + ":" + port
+		ch <- prometheus.MustNewConstMetric(proxySQLServersChecksumsVersionDesc, prometheus.GaugeValue, version, peer, module)
+		ch <- prometheus.MustNewConstMetric(proxySQLServersChecksumsEpochDesc, prometheus.GaugeValue, epoch, peer, module)
+		ch <- prometheus.MustNewConstMetric(proxySQLServersChecksumsDiffCheckDesc, prometheus.GaugeValue, diffCheck, peer, module)
+
+		if checksums[module] == nil {
+			checksums[module] = make(map[string]struct{})
+			modules = append(modules, module)
+		}
+		// peer was not checked yet
+		if checksum.String == "" {
+			continue
+		}
+		checksums[module][checksum.String] = struct{}{}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, module := range modules {
+		ch <- prometheus.MustNewConstMetric(proxySQLServersChecksumsDistinctDesc, prometheus.GaugeValue, float64(len(checksums[module])), module)
+	}
+	return nil
+}
+
+const mySQLUsersQuery = "SELECT username, frontend_connections, frontend_max_connections FROM stats_mysql_users"
+
+// https://github.com/sysown/proxysql/wiki/STATS-(statistics)#stats_mysql_users
+// key - column name in lowercase.
+var mySQLUsersMetrics = map[string]*metric{
+	"frontend_connections": {"frontend_connections", prometheus.GaugeValue,
+		"The number of frontend connections currently used by the user."},
+	"frontend_max_connections": {"frontend_max_connections", prometheus.GaugeValue,
+		"The maximum number of frontend connections the user is allowed to use."},
+	"frontend_connections_ratio": {"frontend_connections_ratio", prometheus.GaugeValue,
+		"The ratio of frontend connections currently used by the user to the maximum allowed."},
+}
+
+type mySQLUsersResult struct {
+	username                    string
+	connections, maxConnections float64
+}
+
+// scrapeMySQLUsers collects metrics from `stats_mysql_users`.
+func scrapeMySQLUsers(db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.Query(mySQLUsersQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res mySQLUsersResult
+		if err = rows.Scan(&res.username, &res.connections, &res.maxConnections); err != nil {
+			return err
+		}
+
+		values := map[string]float64{
+			"frontend_connections":     res.connections,
+			"frontend_max_connections": res.maxConnections,
+		}
+		columns := []string{"frontend_connections", "frontend_max_connections"}
+		// ratio is undefined if user is not allowed to connect at all
+		if res.maxConnections > 0 {
+			values["frontend_connections_ratio"] = res.connections / res.maxConnections
+			columns = append(columns, "frontend_connections_ratio")
+		}
+
+		for _, column := range columns {
+			m := mySQLUsersMetrics[column]
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "mysql_users", m.name),
+					m.help,
+					[]string{"username"}, nil,
+				),
+				m.valueType, values[column],
+				res.username,
+			)
+		}
+	}
+	return rows.Err()
+}
+
+const mySQLQueryRulesQuery = `SELECT s.rule_id, s.hits, r.active, r.destination_hostgroup, r.comment
+	FROM stats_mysql_query_rules s LEFT JOIN runtime_mysql_query_rules r ON r.rule_id = s.rule_id`
+
+// https://github.com/sysown/proxysql/wiki/STATS-(statistics)#stats_mysql_query_rules
+// key - column name in lowercase.
+var mySQLQueryRulesMetrics = map[string]*metric{
+	"hits": {"hits", prometheus.CounterValue,
+		"The total number of times the query rule was matched."},
+}
+
+type mySQLQueryRulesResult struct {
+	ruleID                                string
+	hits                                  float64
+	active, destinationHostgroup, comment sql.NullString
+}
+
+// scrapeMySQLQueryRules collects metrics from `stats_mysql_query_rules` joined with `runtime_mysql_query_rules`.
+// Rule comment is exported as a label if withComment is true.
+func scrapeMySQLQueryRules(db *sql.DB, ch chan<- prometheus.Metric, withComment bool) error {
+	rows, err := db.Query(mySQLQueryRulesQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	m := mySQLQueryRulesMetrics["hits"]
+	labels := []string{"rule_id", "active", "destination_hostgroup"}
+	if withComment {
+		labels = append(labels, "comment")
+	}
+	desc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "query_rules", m.name),
+		m.help,
+		labels, nil,
+	)
+
+	for rows.Next() {
+		var res mySQLQueryRulesResult
+		if err = rows.Scan(&res.ruleID, &res.hits, &res.active, &res.destinationHostgroup, &res.comment); err != nil {
+			return err
+		}
+
+		values := []string{res.ruleID, res.active.String, res.destinationHostgroup.String}
+		if withComment {
+			values = append(values, res.comment.String)
+		}
+		ch <- prometheus.MustNewConstMetric(desc, m.valueType, res.hits, values...)
+	}
+	return rows.Err()
+}
+
+const mySQLFreeConnectionsQuery = `SELECT hostgroup, srv_host, srv_port, autocommit, init_connect, time_zone, sql_mode, idle_ms, mysql_info
+	FROM stats_mysql_free_connections`
+
+// Upper bounds in seconds of free connections idle time histogram.
+var mySQLFreeConnectionsIdleBuckets = []float64{1, 5, 10, 30, 60, 300, 600, 1800, 3600}
+
+// https://github.com/sysown/proxysql/wiki/STATS-(statistics)#stats_mysql_free_connections
+var (
+	mySQLFreeConnectionsCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "free_connections", "count"),
+		"The number of idle connections to the backend server in the connection pool.",
+		[]string{"hostgroup", "endpoint"}, nil,
+	)
+	mySQLFreeConnectionsIdleDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "free_connections", "idle_seconds"),
+		"Histogram of time in seconds since idle connections to the backend server were last used.",
+		[]string{"hostgroup", "endpoint"}, nil,
+	)
+	mySQLFreeConnectionsAutocommitOffDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "free_connections", "autocommit_off"),
+		"The number of idle connections to the backend server with autocommit disabled.",
+		[]string{"hostgroup", "endpoint"}, nil,
+	)
+	mySQLFreeConnectionsSessionVariablesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "free_connections", "session_variables"),
+		"The number of idle connections to the backend server with init_connect, time_zone or sql_mode set.",
+		[]string{"hostgroup", "endpoint"}, nil,
+	)
+	mySQLFreeConnectionsCharsetDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "free_connections", "charset"),
+		"The number of idle connections to the backend server per character set number.",
+		[]string{"hostgroup", "endpoint", "charset"}, nil,
+	)
+)
+
+type freeConnectionsResult struct {
+	hostgroup, endpoint             string
+	autocommitOff, sessionVariables float64
+	idleCounts                      []uint64 // non-cumulative, one more for +Inf
+	idleSum                         float64
+	charsets                        map[string]float64
+	charsetNames                    []string // in order of appearance
+}
+
+// scrapeMySQLFreeConnections aggregates idle backend connections from `stats_mysql_free_connections`
+// per hostgroup and backend server.
+func scrapeMySQLFreeConnections(db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.Query(mySQLFreeConnectionsQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var results []*freeConnectionsResult
+	resultsMap := make(map[string]*freeConnectionsResult)
+	for rows.Next() {
+		var hostgroup, srvHost, srvPort string
+		var autocommit, idleMs float64
+		var initConnect, timeZone, sqlMode, mysqlInfo sql.NullString
+		if err = rows.Scan(&hostgroup, &srvHost, &srvPort, &autocommit, &initConnect, &timeZone, &sqlMode, &idleMs, &mysqlInfo); err != nil {
+			return err
+		}
+
+		endpoint := srvHost + ":" + srvPort
+		res := resultsMap[hostgroup+" "+endpoint]
+		if res == nil {
+			res = &freeConnectionsResult{
+				hostgroup:  hostgroup,
+				endpoint:   endpoint,
+				idleCounts: make([]uint64, len(mySQLFreeConnectionsIdleBuckets)+1),
+				charsets:   make(map[string]float64),
+			}
+			resultsMap[hostgroup+" "+endpoint] = res
+			results = append(results, res)
+		}
+
+		// autocommit is -1 if unknown
+		if autocommit == 0 {
+			res.autocommitOff++
+		}
+		if initConnect.String != "" || timeZone.String != "" || sqlMode.String != "" {
+			res.sessionVariables++
+		}
+
+		idle := idleMs / 1000
+		res.idleSum += idle
+		i := sort.SearchFloat64s(mySQLFreeConnectionsIdleBuckets, idle)
+		res.idleCounts[i]++
+
+		var info struct {
+			Charset *int `json:"charset"`
+		}
+		if err := json.Unmarshal([]byte(mysqlInfo.String), &info); err != nil || info.Charset == nil {
+			log.Debugf("stats_mysql_free_connections mysql_info %q: no charset (%v)", mysqlInfo.String, err)
+			continue
+		}
+		charset := strconv.Itoa(*info.Charset)
+		if _, ok := res.charsets[charset]; !ok {
+			res.charsetNames = append(res.charsetNames, charset)
+		}
+		res.charsets[charset]++
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, res := range results {
+		var count uint64
+		buckets := make(map[float64]uint64, len(mySQLFreeConnectionsIdleBuckets))
+		for i, le := range mySQLFreeConnectionsIdleBuckets {
+			count += res.idleCounts[i]
+			buckets[le] = count
+		}
+		count += res.idleCounts[len(mySQLFreeConnectionsIdleBuckets)]
+
+		ch <- prometheus.MustNewConstMetric(mySQLFreeConnectionsCountDesc, prometheus.GaugeValue, float64(count), res.hostgroup, res.endpoint)
+		ch <- prometheus.MustNewConstHistogram(mySQLFreeConnectionsIdleDesc, count, res.idleSum, buckets, res.hostgroup, res.endpoint)
+		ch <- prometheus.MustNewConstMetric(mySQLFreeConnectionsAutocommitOffDesc, prometheus.GaugeValue, res.autocommitOff, res.hostgroup, res.endpoint)
+		ch <- prometheus.MustNewConstMetric(mySQLFreeConnectionsSessionVariablesDesc, prometheus.GaugeValue, res.sessionVariables, res.hostgroup, res.endpoint)
+		for _, charset := range res.charsetNames {
+			ch <- prometheus.MustNewConstMetric(mySQLFreeConnectionsCharsetDesc, prometheus.GaugeValue, res.charsets[charset], res.hostgroup, res.endpoint, charset)
+		}
+	}
+	return nil
+}
+
+const (
+	preparedStatementsQuery = `SELECT schemaname, username, COUNT(*) AS count,
+	SUM(ref_count_client) AS ref_count_client, SUM(ref_count_server) AS ref_count_server
+	FROM stats_mysql_prepared_statements_info GROUP BY schemaname, username`
+
+	preparedStatementsTopQuery = `SELECT schemaname, username, digest,
+	SUM(ref_count_client) AS ref_count_client, SUM(ref_count_server) AS ref_count_server
+	FROM stats_mysql_prepared_statements_info GROUP BY schemaname, username, digest
+	ORDER BY ref_count_client DESC LIMIT %d`
+)
+
+// https://github.com/sysown/proxysql/wiki/STATS-(statistics)#stats_mysql_prepared_statements_info
+// Order matches value columns in preparedStatementsQuery.
+var preparedStatementsColumns = []string{"count", "ref_count_client", "ref_count_server"}
+
+// key - column name in lowercase.
+var preparedStatementsMetrics = map[string]*metric{
+	"count": {"count", prometheus.GaugeValue,
+		"The number of prepared statements in the global cache."},
+	"ref_count_client": {"ref_count_client", prometheus.GaugeValue,
+		"The number of references to prepared statements from frontend connections."},
+	"ref_count_server": {"ref_count_server", prometheus.GaugeValue,
+		"The number of references to prepared statements from backend connections."},
+}
+
+// key - column name in lowercase.
+var preparedStatementsTopMetrics = map[string]*metric{
+	"ref_count_client": {"top_ref_count_client", prometheus.GaugeValue,
+		"The number of references to the prepared statement from frontend connections, for top statements only."},
+	"ref_count_server": {"top_ref_count_server", prometheus.GaugeValue,
+		"The number of references to the prepared statement from backend connections, for top statements only."},
+}
+
+// scrapePreparedStatements collects metrics from `stats_mysql_prepared_statements_info`.
+// Top limit statements by ref_count_client are exported by digest, without query text.
+func scrapePreparedStatements(db *sql.DB, ch chan<- prometheus.Metric, limit int) error {
+	rows, err := db.Query(preparedStatementsQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, username string
+		values := make([]float64, len(preparedStatementsColumns))
+		if err = rows.Scan(&schemaName, &username, &values[0], &values[1], &values[2]); err != nil {
+			return err
+		}
+
+		for i, column := range preparedStatementsColumns {
+			m := preparedStatementsMetrics[column]
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "prepared_statements", m.name),
+					m.help,
+					[]string{"schemaname", "username"}, nil,
+				),
+				m.valueType, values[i],
+				schemaName, username,
+			)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if limit <= 0 {
+		return nil
+	}
+	return scrapePreparedStatementsTop(db, ch, limit)
+}
+
+func scrapePreparedStatementsTop(db *sql.DB, ch chan<- prometheus.Metric, limit int) error {
+	rows, err := db.Query(fmt.Sprintf(preparedStatementsTopQuery, limit))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, username, digest string
+		values := make([]float64, 2)
+		if err = rows.Scan(&schemaName, &username, &digest, &values[0], &values[1]); err != nil {
+			return err
+		}
+
+		for i, column := range []string{"ref_count_client", "ref_count_server"} {
+			m := preparedStatementsTopMetrics[column]
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "prepared_statements", m.name),
+					m.help,
+					[]string{"schemaname", "username", "digest"}, nil,
+				),
+				m.valueType, values[i],
+				schemaName, username, digest,
+			)
+		}
+	}
+	return rows.Err()
+}
+
+const (
+	gtidExecutedQuery = "SELECT hostname, port, gtid_executed, events FROM stats_mysql_gtid_executed"
+
+	// Backend servers of replication hostgroups, writers first.
+	gtidReplicationServersQuery = `SELECT DISTINCT h.writer_hostgroup, s.hostgroup_id = h.writer_hostgroup AS writer, s.hostname, s.port
+	FROM runtime_mysql_replication_hostgroups h
+	JOIN runtime_mysql_servers s ON s.hostgroup_id IN (h.writer_hostgroup, h.reader_hostgroup)
+	ORDER BY writer DESC`
+)
+
+// https://github.com/sysown/proxysql/wiki/STATS-(statistics)#stats_mysql_gtid_executed
+var (
+	gtidExecutedEventsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "gtid_executed", "events"),
+		"The number of GTID events received from the backend server by ProxySQL Binlog Reader.",
+		[]string{"endpoint"}, nil,
+	)
+	gtidExecutedLagDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "gtid_executed", "lag_transactions"),
+		"The number of transactions executed on writers of the replication hostgroup, but not yet on the backend server.",
+		[]string{"endpoint", "writer_hostgroup"}, nil,
+	)
+)
+
+// scrapeGTIDExecuted collects metrics from `stats_mysql_gtid_executed`,
+// and computes GTID lag of each backend server in replication hostgroups relative to its writers.
+func scrapeGTIDExecuted(db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.Query(gtidExecutedQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	sets := make(map[string]gtidSet) // key - endpoint
+	for rows.Next() {
+		var hostname, port string
+		var gtidExecuted sql.NullString
+		var events float64
+		if err = rows.Scan(&hostname, &port, &gtidExecuted, &events); err != nil {
+			return err
+		}
+
+		endpoint := hostname + ":" + port
+		ch <- prometheus.MustNewConstMetric(gtidExecutedEventsDesc, prometheus.CounterValue, events, endpoint)
+
+		set, err := parseGTIDSet(gtidExecuted.String)
+		if err != nil {
+			log.Debugf("endpoint %s: %s", endpoint, err)
+			continue
+		}
+		sets[endpoint] = set
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	return scrapeGTIDLag(db, ch, sets)
+}
+
+func scrapeGTIDLag(db *sql.DB, ch chan<- prometheus.Metric, sets map[string]gtidSet) error {
+	rows, err := db.Query(gtidReplicationServersQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var writerHostgroups []string
+	writers := make(map[string]gtidSet)  // key - writer hostgroup, value - union of writers sets
+	readers := make(map[string][]string) // key - writer hostgroup, value - endpoints
+	for rows.Next() {
+		var writerHostgroup, hostname, port string
+		var writer bool
+		if err = rows.Scan(&writerHostgroup, &writer, &hostname, &port); err != nil {
+			return err
+		}
+
+		endpoint := hostname + ":" + port
+		set, ok := sets[endpoint]
+		if !ok {
+			continue
+		}
+
+		if _, ok = writers[writerHostgroup]; !ok {
+			writerHostgroups = append(writerHostgroups, writerHostgroup)
+			writers[writerHostgroup] = make(gtidSet)
+		}
+		if writer {
+			writers[writerHostgroup] = writers[writerHostgroup].union(set)
+		} else {
+			readers[writerHostgroup] = append(readers[writerHostgroup], endpoint)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, writerHostgroup := range writerHostgroups {
+		// nothing to compare with
+		if len(writers[writerHostgroup]) == 0 {
+			continue
+		}
+		for _, endpoint := range readers[writerHostgroup] {
+			lag := writers[writerHostgroup].missing(sets[endpoint])
+			ch <- prometheus.MustNewConstMetric(gtidExecutedLagDesc, prometheus.GaugeValue, float64(lag), endpoint, writerHostgroup)
+		}
+	}
+	return nil
+}
+
+const statsHistoryQuery = "SELECT * FROM stats_history.%s ORDER BY timestamp DESC LIMIT 1"
+
+// statsHistoryTable describes a time series table in ProxySQL's `stats_history` schema.
+type statsHistoryTable struct {
+	table   string
+	metrics map[string]*metric // key - column name in lowercase
+}
+
+// https://github.com/sysown/proxysql/wiki/STATS-(statistics)#stats_history
+var statsHistoryTables = []statsHistoryTable{
+	{
+		table: "system_cpu",
+		metrics: map[string]*metric{
+			"tms_utime": {"tms_utime", prometheus.CounterValue,
+				"CPU time in clock ticks spent by ProxySQL in user mode."},
+			"tms_stime": {"tms_stime", prometheus.CounterValue,
+				"CPU time in clock ticks spent by ProxySQL in kernel mode."},
+		},
+	},
+	{
+		table: "system_memory",
+		metrics: map[string]*metric{
+			"allocated": {"allocated", prometheus.GaugeValue,
+				"Bytes allocated by ProxySQL."},
+			"resident": {"resident", prometheus.GaugeValue,
+				"Bytes in physically resident data pages mapped by the allocator."},
+			"active": {"active", prometheus.GaugeValue,
+				"Bytes in pages allocated by ProxySQL."},
+			"mapped": {"mapped", prometheus.GaugeValue,
+				"Bytes in extents mapped by the allocator."},
+			"metadata": {"metadata", prometheus.GaugeValue,
+				"Bytes dedicated to allocator metadata."},
+			"retained": {"retained", prometheus.GaugeValue,
+				"Bytes in virtual memory mappings retained by the allocator."},
+		},
+	},
+	{
+		table: "mysql_connections",
+		metrics: map[string]*metric{
+			"client_connections_aborted": {"client_connections_aborted", prometheus.CounterValue,
+				"Total number of frontend connections aborted."},
+			"client_connections_connected": {"client_connections_connected", prometheus.GaugeValue,
+				"Current number of frontend connections."},
+			"client_connections_created": {"client_connections_created", prometheus.CounterValue,
+				"Total number of frontend connections created."},
+			"server_connections_aborted": {"server_connections_aborted", prometheus.CounterValue,
+				"Total number of backend connections aborted."},
+			"server_connections_connected": {"server_connections_connected", prometheus.GaugeValue,
+				"Current number of backend connections."},
+			"server_connections_created": {"server_connections_created", prometheus.CounterValue,
+				"Total number of backend connections created."},
+			"connpool_get_conn_failure": {"connpool_get_conn_failure", prometheus.CounterValue,
+				"Total number of requests for a connection from the connection pool which failed."},
+			"connpool_get_conn_immediate": {"connpool_get_conn_immediate", prometheus.CounterValue,
+				"Total number of requests for a connection served from the thread local connection cache."},
+			"connpool_get_conn_success": {"connpool_get_conn_success", prometheus.CounterValue,
+				"Total number of requests for a connection from the connection pool which succeeded."},
+			"questions": {"questions", prometheus.CounterValue,
+				"Total number of queries sent from frontends."},
+			"slow_queries": {"slow_queries", prometheus.CounterValue,
+				"Total number of queries that ran for longer than mysql-long_query_time."},
+			"gtid_consistent_queries": {"gtid_consistent_queries", prometheus.CounterValue,
+				"Total number of queries routed with GTID causal consistency."},
+		},
+	},
+	{
+		table: "myhgm_connections",
+		metrics: map[string]*metric{
+			"myhgm_myconnpoll_destroy": {"myhgm_myconnpoll_destroy", prometheus.CounterValue,
+				"Total number of backend connections destroyed by the hostgroups manager."},
+			"myhgm_myconnpoll_get": {"myhgm_myconnpoll_get", prometheus.CounterValue,
+				"Total number of requests for a connection made to the hostgroups manager."},
+			"myhgm_myconnpoll_get_ok": {"myhgm_myconnpoll_get_ok", prometheus.CounterValue,
+				"Total number of requests for a connection made to the hostgroups manager which succeeded."},
+			"myhgm_myconnpoll_push": {"myhgm_myconnpoll_push", prometheus.CounterValue,
+				"Total number of backend connections returned to the hostgroups manager."},
+			"myhgm_myconnpoll_reset": {"myhgm_myconnpoll_reset", prometheus.CounterValue,
+				"Total number of backend connections reset by the hostgroups manager."},
+		},
+	},
+}
+
+// scrapeStatsHistory collects the latest row from each table in `stats_history` schema.
+func scrapeStatsHistory(db *sql.DB, ch chan<- prometheus.Metric) error {
+	for _, t := range statsHistoryTables {
+		if err := scrapeStatsHistoryTable(db, ch, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scrapeStatsHistoryTable(db *sql.DB, ch chan<- prometheus.Metric, t statsHistoryTable) error {
+	rows, err := db.Query(fmt.Sprintf(statsHistoryQuery, t.table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	scan := make([]interface{}, len(columns))
+	for i := range scan {
+		scan[i] = new(sql.NullString)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(scan...); err != nil {
+			return err
+		}
+
+		for i, column := range columns {
+			column = strings.ToLower(column)
+			if column == "timestamp" {
+				continue
+			}
+
+			value, err := strconv.ParseFloat(scan[i].(*sql.NullString).String, 64)
+			if err != nil {
+				log.Debugf("column %s: %s", column, err)
+				continue
+			}
+
+			m := t.metrics[column]
+			if m == nil {
+				m = &metric{
+					name:      column,
+					valueType: prometheus.UntypedValue,
+					help:      "Undocumented stats_history." + t.table + " metric.",
+				}
+			}
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "history_"+t.table, m.name),
+					m.help,
+					nil, nil,
+				),
+				m.valueType, value,
+			)
+		}
+	}
+	return rows.Err()
+}
+
+const globalVariablesQuery = "SELECT variable_name, variable_value FROM runtime_global_variables"
+
+// https://github.com/sysown/proxysql/wiki/Global-variables
+var globalVariablesMetrics = map[string]*metric{
+	"global_variable": {"global_variable", prometheus.GaugeValue,
+		"The value of numeric or boolean ProxySQL global variable."},
+	"global_variable_info": {"global_variable_info", prometheus.GaugeValue,
+		"The value of non-numeric ProxySQL global variable."},
+}
+
+// compileGlobalVariablesFilter returns compiled regular expression, or nil for empty one.
+func compileGlobalVariablesFilter(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+// scrapeGlobalVariables collects metrics from `runtime_global_variables`.
+// Variables not matching include or matching exclude regular expression are skipped; nil expressions are ignored.
+func scrapeGlobalVariables(db *sql.DB, ch chan<- prometheus.Metric, include, exclude *regexp.Regexp) error {
+	rows, err := db.Query(globalVariablesQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, value string
+		if err = rows.Scan(&name, &value); err != nil {
+			return err
+		}
+
+		if include != nil && !include.MatchString(name) {
+			continue
+		}
+		if exclude != nil && exclude.MatchString(name) {
+			continue
+		}
+
+		var f float64
+		switch value {
+		case "true":
+			f = 1
+		case "false":
+			f = 0
+		default:
+			if f, err = strconv.ParseFloat(value, 64); err != nil {
+				m := globalVariablesMetrics["global_variable_info"]
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						prometheus.BuildFQName(namespace, "", m.name),
+						m.help,
+						[]string{"name", "value"}, nil,
+					),
+					m.valueType, 1,
+					name, value,
+				)
+				continue
+			}
+		}
+
+		m := globalVariablesMetrics["global_variable"]
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "", m.name),
+				m.help,
+				[]string{"name"}, nil,
+			),
+			m.valueType, f,
+			name,
+		)
+	}
+	return rows.Err()
+}
+
+const runtimeChecksumsQuery = "SELECT name, version, epoch, checksum FROM runtime_checksums_values"
+
+// https://github.com/sysown/proxysql/wiki/ProxySQL-Cluster#runtime_checksums_values
+// key - column name in lowercase.
+var runtimeChecksumsMetrics = map[string]*metric{
+	"version": {"version", prometheus.GaugeValue,
+		"The version of the module configuration loaded to runtime."},
+	"epoch": {"epoch", prometheus.GaugeValue,
+		"The Unix time when the module configuration was loaded to runtime."},
+	"info": {"info", prometheus.GaugeValue,
+		"The checksum of the module configuration loaded to runtime."},
+}
+
+// checksumChanges counts module checksum changes between scrapes.
+type checksumChanges struct {
+	total *prometheus.CounterVec
+
+	// held across query and update of last, so concurrent scrapes apply checksums in order of queries
+	m    sync.Mutex
+	last map[string]string // key - module name, value - checksum seen by the previous scrape
+}
+
+func newChecksumChanges() *checksumChanges {
+	return &checksumChanges{
+		total: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "runtime_checksums",
+			Name:      "changes_total",
+			Help:      "Total number of module checksum changes seen between scrapes.",
+		}, []string{"module"}),
+		last: make(map[string]string),
+	}
+}
+
+// scrapeRuntimeChecksums collects metrics from `runtime_checksums_values`,
+// and counts module checksums which changed since the previous scrape.
+func scrapeRuntimeChecksums(db *sql.DB, ch chan<- prometheus.Metric, changes *checksumChanges) error {
+	changes.m.Lock()
+	defer changes.m.Unlock()
+
+	rows, err := db.Query(runtimeChecksumsQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var module, checksum string
+		var version, epoch float64
+		if err = rows.Scan(&module, &version, &epoch, &checksum); err != nil {
+			return err
+		}
+
+		values := map[string]float64{
+			"version": version,
+			"epoch":   epoch,
+		}
+		for _, column := range []string{"version", "epoch"} {
+			m := runtimeChecksumsMetrics[column]
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "runtime_checksums", m.name),
+					m.help,
+					[]string{"module"}, nil,
+				),
+				m.valueType, values[column],
+				module,
+			)
+		}
+
+		m := runtimeChecksumsMetrics["info"]
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "runtime_checksums", m.name),
+				m.help,
+				[]string{"module", "checksum"}, nil,
+			),
+			m.valueType, 1,
+			module, checksum,
+		)
+
+		// the first seen checksum is not a change
+		counter := changes.total.WithLabelValues(module)
+		if last, ok := changes.last[module]; ok && last != checksum {
+			counter.Inc()
+		}
+		changes.last[module] = checksum
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	changes.total.Collect(ch)
+	return nil
+}
+
+const (
+	clientHostCacheQuery = `SELECT COUNT(*) AS hosts, COALESCE(SUM(error_count), 0) AS error_count
+	FROM stats_mysql_client_host_cache`
+
+	clientHostCacheTopQuery = `SELECT client_address, error_count, last_updated
+	FROM stats_mysql_client_host_cache ORDER BY error_count DESC, client_address LIMIT %d`
+)
+
+// https://github.com/sysown/proxysql/wiki/STATS-(statistics)#stats_mysql_client_host_cache
+// key - column name in lowercase.
+var clientHostCacheMetrics = map[string]*metric{
+	"hosts": {"hosts", prometheus.GaugeValue,
+		"The number of client hosts with failed connection attempts in the cache."},
+	"error_count": {"error_count", prometheus.GaugeValue,
+		"The number of consecutive failed connection attempts of all client hosts in the cache."},
+}
+
+// key - column name in lowercase.
+var clientHostCacheTopMetrics = map[string]*metric{
+	"error_count": {"top_error_count", prometheus.GaugeValue,
+		"The number of consecutive failed connection attempts of the client host, for top hosts only."},
+	"last_updated": {"top_last_updated_seconds", prometheus.GaugeValue,
+		"ProxySQL monotonic time of the last failed connection attempt of the client host in seconds, for top hosts only."},
+}
+
+// scrapeClientHostCache collects metrics from `stats_mysql_client_host_cache`.
+// Top limit client hosts by error_count are exported by client address.
+func scrapeClientHostCache(db *sql.DB, ch chan<- prometheus.Metric, limit int) error {
+	var hosts, errorCount float64
+	if err := db.QueryRow(clientHostCacheQuery).Scan(&hosts, &errorCount); err != nil {
+		return err
+	}
+
+	values := map[string]float64{
+		"hosts":       hosts,
+		"error_count": errorCount,
+	}
+	for _, column := range []string{"hosts", "error_count"} {
+		m := clientHostCacheMetrics[column]
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "client_host_cache", m.name),
+				m.help,
+				nil, nil,
+			),
+			m.valueType, values[column],
+		)
+	}
+
+	if limit <= 0 {
+		return nil
+	}
+	return scrapeClientHostCacheTop(db, ch, limit)
+}
+
+func scrapeClientHostCacheTop(db *sql.DB, ch chan<- prometheus.Metric, limit int) error {
+	rows, err := db.Query(fmt.Sprintf(clientHostCacheTopQuery, limit))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var clientAddress string
+		var errorCount, lastUpdated float64
+		if err = rows.Scan(&clientAddress, &errorCount, &lastUpdated); err != nil {
+			return err
+		}
+
+		values := map[string]float64{
+			"error_count":  errorCount,
+			"last_updated": lastUpdated / 1e6,
+		}
+		for _, column := range []string{"error_count", "last_updated"} {
+			m := clientHostCacheTopMetrics[column]
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "client_host_cache", m.name),
+					m.help,
+					[]string{"client_address"}, nil,
+				),
+				m.valueType, values[column],
+				clientAddress,
+			)
+		}
+	}
+	return rows.Err()
+}
+
+const mySQLConnectionListQuery = "SELECT COUNT(cli_host) as connection_count, cli_host FROM stats_mysql_processlist GROUP BY cli_host"
+
+var mySQLconnectionListMetrics = map[string]*metric{
+	"connection_count": {"client_connection_list", prometheus.GaugeValue,
+		"Total number of frontend connections"},
+}
+
+// scrapeMySQLConnectionList collects connection list from `stats_mysql_processlist`.
+func scrapeMySQLConnectionList(db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.Query(mySQLConnectionListQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	scan := make([]interface{}, len(columns))
+	var cliHost string
+	var connNum float64
+
+	scan[0], scan[1] = &connNum, &cliHost
+
+	for rows.Next() {
+		if err = rows.Scan(scan...); err != nil {
+			return err
+		}
+
+		column := strings.ToLower(columns[0])
+
+		m := mySQLconnectionListMetrics[column]
+		if m == nil {
+			m = &metric{
+				name:      "client_connection_list",
+				valueType: prometheus.UntypedValue,
+				help:      "Undocumented stats_mysql_processlist metric.",
+			}
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "processlist", m.name),
+				m.help,
+				[]string{"client_host"}, nil,
+			),
+			m.valueType, connNum,
+			cliHost,
+		)
+	}
+	return rows.Err()
+}
+
+const detailedMySQLProcessListQuery = "SELECT user, db, cli_host, hostgroup, COUNT(*) as count from stats_mysql_processlist group by user, db, cli_host, hostgroup"
+
+var detailedMySQLProcessListMetrics = map[string]*metric{
+	"detailed_connection_count": {name: "detailed_client_connection_count", valueType: prometheus.GaugeValue, help: "Number of client connections per user, db, host and hostgroup."},
+}
+
+type processListResult struct {
+	user, db, clientHost, hostGroup string
+	count                           float64
+}
+
+func scrapeDetailedMySQLConnectionList(db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.Query(detailedMySQLProcessListQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+
+	for rows.Next() {
+		var res processListResult
+
+		err := rows.Scan(&res.user, &res.db, &res.clientHost, &res.hostGroup, &res.count)
+		if err != nil {
+			return err
+		}
+
+		m := detailedMySQLProcessListMetrics["detailed_connection_count"]
+
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "processlist", m.name),
+				m.help,
+				[]string{"user", "db", "client_host", "hostgroup"},
+				nil,
+			),
+			m.valueType,
+			res.count,
+			res.user, res.db, res.clientHost, res.hostGroup,
+		)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	return scrapeMySQLProcessListSessions(db, ch)
+}
+
+const mySQLProcessListSessionsQuery = "SELECT user, hostgroup, command, time_ms FROM stats_mysql_processlist"
+
+// Upper bounds in seconds of active queries time histogram.
+var mySQLProcessListQueryBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 600, 1200}
+
+// https://github.com/sysown/proxysql/wiki/STATS-(statistics)#stats_mysql_processlist
+var (
+	mySQLProcessListCommandDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "processlist", "command_sessions"),
+		"The number of client sessions per command, e.g. Query or Sleep.",
+		[]string{"command"}, nil,
+	)
+	mySQLProcessListQueryDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "processlist", "query_time_seconds"),
+		"Histogram of time in seconds active queries have been running for per hostgroup.",
+		[]string{"hostgroup"}, nil,
+	)
+	mySQLProcessListLongestQueryDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "processlist", "longest_query_seconds"),
+		"Time in seconds the longest active query of the user has been running for.",
+		[]string{"user"}, nil,
+	)
+)
+
+type processListQueryResult struct {
+	counts []uint64 // non-cumulative, one more for +Inf
+	sum    float64
+}
+
+// scrapeMySQLProcessListSessions aggregates client sessions from `stats_mysql_processlist`
+// by command, and active queries (all commands but Sleep) by hostgroup and user.
+func scrapeMySQLProcessListSessions(db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.Query(mySQLProcessListSessionsQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var commands, hostgroups, users []string // in order of appearance
+	commandsMap := make(map[string]float64)
+	hostgroupsMap := make(map[string]*processListQueryResult)
+	usersMap := make(map[string]float64)
+	for rows.Next() {
+		var user, hostgroup, command string
+		var timeMs float64
+		if err = rows.Scan(&user, &hostgroup, &command, &timeMs); err != nil {
+			return err
+		}
+
+		if _, ok := commandsMap[command]; !ok {
+			commands = append(commands, command)
+		}
+		commandsMap[command]++
+
+		if command == "Sleep" {
+			continue
+		}
+
+		t := timeMs / 1000
+		res := hostgroupsMap[hostgroup]
+		if res == nil {
+			res = &processListQueryResult{
+				counts: make([]uint64, len(mySQLProcessListQueryBuckets)+1),
+			}
+			hostgroupsMap[hostgroup] = res
+			hostgroups = append(hostgroups, hostgroup)
+		}
+		res.sum += t
+		res.counts[sort.SearchFloat64s(mySQLProcessListQueryBuckets, t)]++
+
+		if longest, ok := usersMap[user]; !ok || t > longest {
+			if !ok {
+				users = append(users, user)
+			}
+			usersMap[user] = t
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, command := range commands {
+		ch <- prometheus.MustNewConstMetric(mySQLProcessListCommandDesc, prometheus.GaugeValue, commandsMap[command], command)
+	}
+	for _, hostgroup := range hostgroups {
+		res := hostgroupsMap[hostgroup]
+		var count uint64
+		buckets := make(map[float64]uint64, len(mySQLProcessListQueryBuckets))
+		for i, le := range mySQLProcessListQueryBuckets {
+			count += res.counts[i]
+			buckets[le] = count
+		}
+		count += res.counts[len(mySQLProcessListQueryBuckets)]
+		ch <- prometheus.MustNewConstHistogram(mySQLProcessListQueryDesc, count, res.sum, buckets, hostgroup)
+	}
+	for _, user := range users {
+		ch <- prometheus.MustNewConstMetric(mySQLProcessListLongestQueryDesc, prometheus.GaugeValue, usersMap[user], user)
+	}
+	return nil
 }
 
 const memoryMetricsQuery = "select Variable_Name, Variable_Value  from stats_memory_metrics"
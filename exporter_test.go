@@ -16,9 +16,11 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -79,9 +81,1485 @@ func sanitizeQuery(q string) string {
 	return q
 }
 
+// proxySQLVersions are versions used in the since field of mySQLGlobalMetric.
+var proxySQLVersions = []string{"1.4", "2.0", "2.4"}
+
+// proxySQL2GlobalVariables lists all variables of `stats_mysql_global` in ProxySQL 2.x, as they are named there.
+// Variables which appear only with some options enabled are included.
+var proxySQL2GlobalVariables = []string{
+	"ProxySQL_Uptime",
+	"Active_Transactions",
+	"Client_Connections_aborted",
+	"Client_Connections_connected",
+	"Client_Connections_created",
+	"Server_Connections_aborted",
+	"Server_Connections_connected",
+	"Server_Connections_created",
+	"Server_Connections_delayed",
+	"Client_Connections_non_idle",
+	"Client_Connections_hostgroup_locked",
+	"Client_Connections_sha2cached",
+	"Queries_backends_bytes_recv",
+	"Queries_backends_bytes_sent",
+	"Queries_frontends_bytes_recv",
+	"Queries_frontends_bytes_sent",
+	"Query_Processor_time_nsec",
+	"Backend_query_time_nsec",
+	"mysql_backend_buffers_bytes",
+	"mysql_frontend_buffers_bytes",
+	"mysql_session_internal_bytes",
+	"Com_autocommit",
+	"Com_autocommit_filtered",
+	"Com_commit",
+	"Com_commit_filtered",
+	"Com_rollback",
+	"Com_rollback_filtered",
+	"Com_backend_change_user",
+	"Com_backend_init_db",
+	"Com_backend_set_names",
+	"Com_frontend_init_db",
+	"Com_frontend_set_names",
+	"Com_frontend_use_db",
+	"Com_backend_stmt_prepare",
+	"Com_backend_stmt_execute",
+	"Com_backend_stmt_close",
+	"Com_frontend_stmt_prepare",
+	"Com_frontend_stmt_execute",
+	"Com_frontend_stmt_close",
+	"Mirror_concurrency",
+	"Mirror_queue_length",
+	"Questions",
+	"Selects_for_update__autocommit0",
+	"Slow_queries",
+	"GTID_consistent_queries",
+	"GTID_session_collected",
+	"Servers_table_version",
+	"MySQL_Thread_Workers",
+	"Access_Denied_Wrong_Password",
+	"Access_Denied_Max_Connections",
+	"Access_Denied_Max_User_Connections",
+	"MySQL_Monitor_Workers",
+	"MySQL_Monitor_Workers_Aux",
+	"MySQL_Monitor_Workers_Started",
+	"MySQL_Monitor_connect_check_OK",
+	"MySQL_Monitor_connect_check_ERR",
+	"MySQL_Monitor_ping_check_OK",
+	"MySQL_Monitor_ping_check_ERR",
+	"MySQL_Monitor_read_only_check_OK",
+	"MySQL_Monitor_read_only_check_ERR",
+	"MySQL_Monitor_replication_lag_check_OK",
+	"MySQL_Monitor_replication_lag_check_ERR",
+	"MySQL_Monitor_dns_cache_queried",
+	"MySQL_Monitor_dns_cache_lookup_success",
+	"MySQL_Monitor_dns_cache_record_updated",
+	"ConnPool_get_conn_latency_awareness",
+	"ConnPool_get_conn_immediate",
+	"ConnPool_get_conn_success",
+	"ConnPool_get_conn_failure",
+	"generated_error_packets",
+	"max_connect_timeouts",
+	"backend_lagging_during_query",
+	"backend_offline_during_query",
+	"queries_with_max_lag_ms",
+	"queries_with_max_lag_ms__delayed",
+	"queries_with_max_lag_ms__total_wait_time_us",
+	"mysql_unexpected_frontend_com_quit",
+	"hostgroup_locked_set_cmds",
+	"hostgroup_locked_queries",
+	"mysql_unexpected_frontend_packets",
+	"aws_aurora_replicas_skipped_during_query",
+	"automatic_detected_sql_injection",
+	"whitelisted_sqli_fingerprint",
+	"mysql_killed_backend_connections",
+	"mysql_killed_backend_queries",
+	"MyHGM_myconnpoll_get",
+	"MyHGM_myconnpoll_get_ok",
+	"MyHGM_myconnpoll_push",
+	"MyHGM_myconnpoll_destroy",
+	"MyHGM_myconnpoll_reset",
+	"SQLite3_memory_bytes",
+	"ConnPool_memory_bytes",
+	"Stmt_Client_Active_Total",
+	"Stmt_Client_Active_Unique",
+	"Stmt_Server_Active_Total",
+	"Stmt_Server_Active_Unique",
+	"Stmt_Max_Stmt_id",
+	"Stmt_Cached",
+	"Query_Cache_Memory_bytes",
+	"Query_Cache_count_GET",
+	"Query_Cache_count_GET_OK",
+	"Query_Cache_count_SET",
+	"Query_Cache_bytes_IN",
+	"Query_Cache_bytes_OUT",
+	"Query_Cache_Purged",
+	"Query_Cache_Entries",
+}
+
 func TestScrapeMySQLGlobal(t *testing.T) {
 	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
-		for c, m := range mySQLGlobalMetrics {
+		for c, m := range mySQLGlobalMetrics {
+			cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+			cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+		}
+		for _, f := range mySQLGlobalFamilies {
+			for c, m := range f.metrics {
+				cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+				cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+			}
+		}
+	})
+
+	convey.Convey("Metrics are typed and versioned", t, convey.FailureContinues, func(cv convey.C) {
+		for c, m := range mySQLGlobalMetrics {
+			cv.So(m.valueType, convey.ShouldNotEqual, prometheus.UntypedValue)
+			cv.So(m.help, convey.ShouldNotBeEmpty)
+			cv.So(m.since, convey.ShouldBeIn, proxySQLVersions)
+			if mySQLGlobalUnit(c) != 1 {
+				cv.So(m.name, convey.ShouldEndWith, "_seconds")
+			}
+		}
+		for _, f := range mySQLGlobalFamilies {
+			for _, m := range f.metrics {
+				cv.So(m.valueType, convey.ShouldNotEqual, prometheus.UntypedValue)
+				cv.So(m.help, convey.ShouldNotBeEmpty)
+				cv.So(m.since, convey.ShouldBeIn, proxySQLVersions)
+			}
+		}
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"Variable_Name", "Variable_Value"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("Active_Transactions", "3").
+		AddRow("Backend_query_time_nsec", "76355784684851").
+		AddRow("Client_Connections_aborted", "0").
+		AddRow("Client_Connections_connected", "64").
+		AddRow("Client_Connections_created", "1087931").
+		AddRow("Query_Cache_Memory_bytes", "1048576").
+		AddRow("Query_Cache_count_GET", "200").
+		AddRow("Query_Cache_count_GET_OK", "150").
+		AddRow("MySQL_Thread_Workers", "4").
+		AddRow("mysql_frontend_buffers_bytes", "196608").
+		AddRow("mysql_backend_buffers_bytes", "65536").
+		AddRow("Servers_table_version", "2019470").
+		AddRow("Unknown_Variable", "42")
+	mock.ExpectQuery(mySQLGlobalQuery).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLGlobal(db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_mysql_status_active_transactions", prometheus.Labels{}, 3, dto.MetricType_GAUGE},
+		{"proxysql_mysql_status_backend_query_time_seconds", prometheus.Labels{}, 76355.784684851, dto.MetricType_COUNTER},
+		{"proxysql_mysql_status_client_connections_aborted", prometheus.Labels{}, 0, dto.MetricType_COUNTER},
+		{"proxysql_mysql_status_client_connections_connected", prometheus.Labels{}, 64, dto.MetricType_GAUGE},
+		{"proxysql_mysql_status_client_connections_created", prometheus.Labels{}, 1087931, dto.MetricType_COUNTER},
+		{"proxysql_query_cache_memory_bytes", prometheus.Labels{}, 1048576, dto.MetricType_GAUGE},
+		{"proxysql_query_cache_count_get", prometheus.Labels{}, 200, dto.MetricType_COUNTER},
+		{"proxysql_query_cache_count_get_ok", prometheus.Labels{}, 150, dto.MetricType_COUNTER},
+		{"proxysql_threads_mysql_workers", prometheus.Labels{}, 4, dto.MetricType_GAUGE},
+		{"proxysql_buffers_bytes", prometheus.Labels{"side": "frontend"}, 196608, dto.MetricType_GAUGE},
+		{"proxysql_buffers_bytes", prometheus.Labels{"side": "backend"}, 65536, dto.MetricType_GAUGE},
+		{"proxysql_mysql_status_servers_table_version", prometheus.Labels{}, 2019470, dto.MetricType_GAUGE},
+		{"proxysql_mysql_status_unknown_variable", prometheus.Labels{}, 42, dto.MetricType_UNTYPED},
+		{"proxysql_query_cache_hit_ratio", prometheus.Labels{}, 0.75, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLGlobalProxySQL2(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"Variable_Name", "Variable_Value"})
+	for _, name := range proxySQL2GlobalVariables {
+		rows.AddRow(name, "1")
+	}
+	mock.ExpectQuery(mySQLGlobalQuery).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLGlobal(db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	// all variables and query cache hit ratio
+	var n int
+	for m := range ch {
+		got := readMetric(m)
+		assert.NotEqual(t, dto.MetricType_UNTYPED, got.metricType, "%s is not in the catalog", got.name)
+		n++
+	}
+	assert.Equal(t, len(proxySQL2GlobalVariables)+1, n)
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLGlobalError(t *testing.T) {
+	db1, mock1, err1 := sqlmock.New()
+	if err1 != nil {
+		t.Fatalf("error opening a stub database connection: %s", err1)
+	}
+	defer db1.Close()
+
+	mock1.ExpectQuery(mySQLGlobalQuery).WillReturnError(errors.New("an error"))
+	ch1 := make(chan prometheus.Metric)
+
+	go func() {
+		scrapeMySQLGlobal(db1, ch1)
+		close(ch1)
+	}()
+
+	db2, mock2, err2 := sqlmock.New()
+	if err2 != nil {
+		t.Fatalf("error opening a stub database connection: %s", err2)
+	}
+	defer db2.Close()
+
+	columns := []string{"Variable_Name", "Variable_Value"}
+	rows := sqlmock.NewRows(columns).AddRow("Active_Transactions", "3")
+	mock2.ExpectQuery(sanitizeQuery(mySQLGlobalQuery)).WillReturnRows(rows)
+
+	ch2 := make(chan prometheus.Metric)
+	go func() {
+		scrapeMySQLGlobal(db2, ch2)
+		close(ch2)
+	}()
+
+	_ = *readMetric(<-ch2)
+}
+
+func TestScrapeMySQLConnectionPool(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for c, m := range mySQLconnectionPoolMetrics {
+			cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+			cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+		}
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"hostgroup", "srv_host", "srv_port", "status", "ConnUsed", "ConnFree", "ConnOK", "ConnERR",
+		"Queries", "Bytes_data_sent", "Bytes_data_recv", "Latency_us"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("0", "10.91.142.80", "3306", "ONLINE", "0", "45", "1895677", "46", "197941647", "10984550806", "321063484988", "163").
+		AddRow("0", "10.91.142.82", "3306", "SHUNNED", "0", "97", "39859", "0", "386686994", "21643682247", "641406745151", "255").
+		AddRow("1", "10.91.142.88", "3306", "OFFLINE_SOFT", "0", "18", "31471", "6391", "255993467", "14327840185", "420795691329", "283").
+		AddRow("2", "10.91.142.89", "3306", "OFFLINE_HARD", "0", "18", "31471", "6391", "255993467", "14327840185", "420795691329", "283")
+	mock.ExpectQuery(sanitizeQuery(mySQLconnectionPoolQuery)).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLConnectionPool(db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_connection_pool_status", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_connection_pool_conn_used", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_connection_pool_conn_free", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 45, dto.MetricType_GAUGE},
+		{"proxysql_connection_pool_conn_ok", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 1895677, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_conn_err", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 46, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_queries", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 197941647, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_bytes_data_sent", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 10984550806, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_bytes_data_recv", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 321063484988, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_latency_us", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 163, dto.MetricType_GAUGE},
+
+		{"proxysql_connection_pool_status", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 2, dto.MetricType_GAUGE},
+		{"proxysql_connection_pool_conn_used", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_connection_pool_conn_free", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 97, dto.MetricType_GAUGE},
+		{"proxysql_connection_pool_conn_ok", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 39859, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_conn_err", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 0, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_queries", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 386686994, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_bytes_data_sent", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 21643682247, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_bytes_data_recv", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 641406745151, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_latency_us", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 255, dto.MetricType_GAUGE},
+
+		{"proxysql_connection_pool_status", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 3, dto.MetricType_GAUGE},
+		{"proxysql_connection_pool_conn_used", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_connection_pool_conn_free", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 18, dto.MetricType_GAUGE},
+		{"proxysql_connection_pool_conn_ok", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 31471, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_conn_err", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 6391, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_queries", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 255993467, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_bytes_data_sent", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 14327840185, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_bytes_data_recv", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 420795691329, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_latency_us", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 283, dto.MetricType_GAUGE},
+
+		{"proxysql_connection_pool_status", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 4, dto.MetricType_GAUGE},
+		{"proxysql_connection_pool_conn_used", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_connection_pool_conn_free", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 18, dto.MetricType_GAUGE},
+		{"proxysql_connection_pool_conn_ok", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 31471, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_conn_err", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 6391, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_queries", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 255993467, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_bytes_data_sent", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 14327840185, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_bytes_data_recv", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 420795691329, dto.MetricType_COUNTER},
+		{"proxysql_connection_pool_latency_us", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 283, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLConnectionPoolError(t *testing.T) {
+	db1, mock1, err1 := sqlmock.New()
+	if err1 != nil {
+		t.Fatalf("error opening a stub database connection: %s", err1)
+	}
+	defer db1.Close()
+
+	mock1.ExpectQuery(mySQLconnectionPoolQuery).WillReturnError(errors.New("an error"))
+	ch1 := make(chan prometheus.Metric)
+
+	go func() {
+		scrapeMySQLConnectionPool(db1, ch1)
+		close(ch1)
+	}()
+
+	mySQLconnectionPoolMetrics = map[string]*metric{
+		"hostgroup": {},
+		"latency_us": {"latency_us", prometheus.GaugeValue,
+			"The currently ping time in microseconds, as reported from Monitor."},
+		"latency_ms": {"latency_us", prometheus.GaugeValue,
+			"The currently ping time in microseconds, as reported from Monitor."},
+	}
+
+	db2, mock2, err2 := sqlmock.New()
+	if err2 != nil {
+		t.Fatalf("error opening a stub database connection: %s", err2)
+	}
+	defer db2.Close()
+
+	columns := []string{"hostgroup", "srv_host", "srv_port", "status", "ConnUsed", "ConnFree", "ConnOK", "ConnERR",
+		"Queries", "Bytes_data_sent", "Bytes_data_recv", "Latency_us"}
+	rows := sqlmock.NewRows(columns).AddRow("0", "10.91.142.80", "3306", "ONLINE", "0", "45", "1895677", "46", "197941647", "10984550806", "321063484988", "163")
+	mock2.ExpectQuery(sanitizeQuery(mySQLconnectionPoolQuery)).WillReturnRows(rows)
+
+	ch2 := make(chan prometheus.Metric)
+	go func() {
+		scrapeMySQLConnectionPool(db2, ch2)
+		close(ch2)
+	}()
+
+	_ = *readMetric(<-ch2)
+}
+
+func TestScrapeMySQLQueryDigest(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for c, m := range mySQLQueryDigestMetrics {
+			cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+			cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+		}
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"hostgroup", "schemaname", "username", "digest",
+		"count_star", "sum_time", "min_time", "max_time", "rows_affected", "rows_sent"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("1", "sbtest", "app", "0x3765930C7143F468", "100", "9000", "20", "500", "0", "100").
+		AddRow("0", "sbtest", "app", "0xD30AD7E3079ABCE7", "40", "6000", "100", "300", "40", "0").
+		AddRow("1", "sbtest", "report", "0x2B838C3B5DE79958", "10", "2000", "150", "250", "0", "70").
+		AddRow("1", "test", "report", "0x1C46AE529DD5ADE3", "5", "1000", "110", "400", "0", "5")
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(mySQLQueryDigestQuery, queryDigestOrderBySumTime))).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLQueryDigest(db, ch, 2, queryDigestOrderBySumTime); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_query_digest_count_star", prometheus.Labels{"hostgroup": "1", "schemaname": "sbtest", "username": "app", "digest": "0x3765930C7143F468"}, 100, dto.MetricType_COUNTER},
+		{"proxysql_query_digest_sum_time_us", prometheus.Labels{"hostgroup": "1", "schemaname": "sbtest", "username": "app", "digest": "0x3765930C7143F468"}, 9000, dto.MetricType_COUNTER},
+		{"proxysql_query_digest_min_time_us", prometheus.Labels{"hostgroup": "1", "schemaname": "sbtest", "username": "app", "digest": "0x3765930C7143F468"}, 20, dto.MetricType_GAUGE},
+		{"proxysql_query_digest_max_time_us", prometheus.Labels{"hostgroup": "1", "schemaname": "sbtest", "username": "app", "digest": "0x3765930C7143F468"}, 500, dto.MetricType_GAUGE},
+		{"proxysql_query_digest_rows_affected", prometheus.Labels{"hostgroup": "1", "schemaname": "sbtest", "username": "app", "digest": "0x3765930C7143F468"}, 0, dto.MetricType_COUNTER},
+		{"proxysql_query_digest_rows_sent", prometheus.Labels{"hostgroup": "1", "schemaname": "sbtest", "username": "app", "digest": "0x3765930C7143F468"}, 100, dto.MetricType_COUNTER},
+
+		{"proxysql_query_digest_count_star", prometheus.Labels{"hostgroup": "0", "schemaname": "sbtest", "username": "app", "digest": "0xD30AD7E3079ABCE7"}, 40, dto.MetricType_COUNTER},
+		{"proxysql_query_digest_sum_time_us", prometheus.Labels{"hostgroup": "0", "schemaname": "sbtest", "username": "app", "digest": "0xD30AD7E3079ABCE7"}, 6000, dto.MetricType_COUNTER},
+		{"proxysql_query_digest_min_time_us", prometheus.Labels{"hostgroup": "0", "schemaname": "sbtest", "username": "app", "digest": "0xD30AD7E3079ABCE7"}, 100, dto.MetricType_GAUGE},
+		{"proxysql_query_digest_max_time_us", prometheus.Labels{"hostgroup": "0", "schemaname": "sbtest", "username": "app", "digest": "0xD30AD7E3079ABCE7"}, 300, dto.MetricType_GAUGE},
+		{"proxysql_query_digest_rows_affected", prometheus.Labels{"hostgroup": "0", "schemaname": "sbtest", "username": "app", "digest": "0xD30AD7E3079ABCE7"}, 40, dto.MetricType_COUNTER},
+		{"proxysql_query_digest_rows_sent", prometheus.Labels{"hostgroup": "0", "schemaname": "sbtest", "username": "app", "digest": "0xD30AD7E3079ABCE7"}, 0, dto.MetricType_COUNTER},
+
+		{"proxysql_query_digest_other_count_star", prometheus.Labels{}, 15, dto.MetricType_GAUGE},
+		{"proxysql_query_digest_other_sum_time_us", prometheus.Labels{}, 3000, dto.MetricType_GAUGE},
+		{"proxysql_query_digest_other_min_time_us", prometheus.Labels{}, 110, dto.MetricType_GAUGE},
+		{"proxysql_query_digest_other_max_time_us", prometheus.Labels{}, 400, dto.MetricType_GAUGE},
+		{"proxysql_query_digest_other_rows_affected", prometheus.Labels{}, 0, dto.MetricType_GAUGE},
+		{"proxysql_query_digest_other_rows_sent", prometheus.Labels{}, 75, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLQueryDigestError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeMySQLQueryDigest(db, ch, 10, "digest_text")
+	assert.Error(t, err)
+
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(mySQLQueryDigestQuery, queryDigestOrderByCountStar))).WillReturnError(errors.New("error"))
+	err = scrapeMySQLQueryDigest(db, ch, 10, queryDigestOrderByCountStar)
+	assert.Error(t, err)
+}
+
+func TestScrapeMySQLCommandsCounters(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for c, m := range mySQLCommandsCountersMetrics {
+			cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+			cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+		}
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"Command", "Total_Time_us", "Total_cnt", "cnt_100us", "cnt_500us", "cnt_1ms", "cnt_5ms", "cnt_10ms",
+		"cnt_50ms", "cnt_100ms", "cnt_500ms", "cnt_1s", "cnt_5s", "cnt_10s", "cnt_INFs"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("SELECT", "25000000", "1000", "100", "200", "300", "200", "100", "50", "20", "10", "10", "5", "3", "2")
+	mock.ExpectQuery(sanitizeQuery(mySQLCommandsCountersQuery)).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLCommandsCounters(db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_commands_total_time_us", prometheus.Labels{"command": "SELECT"}, 25000000, dto.MetricType_COUNTER},
+		{"proxysql_commands_total_cnt", prometheus.Labels{"command": "SELECT"}, 1000, dto.MetricType_COUNTER},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	convey.Convey("Histogram comparison", t, convey.FailureContinues, func(cv convey.C) {
+		m := <-ch
+		cv.So(getName(m.Desc()), convey.ShouldEqual, "proxysql_commands_latency_seconds")
+
+		pb := &dto.Metric{}
+		cv.So(m.Write(pb), convey.ShouldBeNil)
+		cv.So(pb.GetLabel()[0].GetValue(), convey.ShouldEqual, "SELECT")
+		cv.So(pb.GetHistogram().GetSampleCount(), convey.ShouldEqual, 1000)
+		cv.So(pb.GetHistogram().GetSampleSum(), convey.ShouldEqual, 25)
+
+		buckets := make(map[float64]uint64)
+		for _, b := range pb.GetHistogram().GetBucket() {
+			buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+		}
+		cv.So(buckets, convey.ShouldResemble, map[float64]uint64{
+			0.0001: 100, 0.0005: 300, 0.001: 600, 0.005: 800, 0.01: 900, 0.05: 950,
+			0.1: 970, 0.5: 980, 1: 990, 5: 995, 10: 998,
+		})
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLCommandsCountersError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(mySQLCommandsCountersQuery)).WillReturnError(errors.New("error"))
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeMySQLCommandsCounters(db, ch)
+	assert.Error(t, err)
+}
+
+func TestScrapeMySQLErrors(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for c, m := range mySQLErrorsMetrics {
+			cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+			cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+		}
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"hostgroup", "hostname", "port", "username", "schemaname", "errno", "count_star", "first_seen", "last_seen"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("0", "10.91.142.80", "3306", "app", "sbtest", "1213", "12", "1538092542", "1538096142").
+		AddRow("1", "10.91.142.82", "3306", "report", "", "1045", "3", "1538092000", "1538092100")
+	mock.ExpectQuery(sanitizeQuery(mySQLErrorsQuery)).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLErrors(db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_mysql_errors_count_star", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306", "username": "app", "schemaname": "sbtest", "errno": "1213"}, 12, dto.MetricType_COUNTER},
+		{"proxysql_mysql_errors_first_seen", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306", "username": "app", "schemaname": "sbtest", "errno": "1213"}, 1538092542, dto.MetricType_GAUGE},
+		{"proxysql_mysql_errors_last_seen", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306", "username": "app", "schemaname": "sbtest", "errno": "1213"}, 1538096142, dto.MetricType_GAUGE},
+
+		{"proxysql_mysql_errors_count_star", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.82:3306", "username": "report", "schemaname": "", "errno": "1045"}, 3, dto.MetricType_COUNTER},
+		{"proxysql_mysql_errors_first_seen", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.82:3306", "username": "report", "schemaname": "", "errno": "1045"}, 1538092000, dto.MetricType_GAUGE},
+		{"proxysql_mysql_errors_last_seen", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.82:3306", "username": "report", "schemaname": "", "errno": "1045"}, 1538092100, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLErrorsError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(mySQLErrorsQuery)).WillReturnError(errors.New("error"))
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeMySQLErrors(db, ch)
+	assert.Error(t, err)
+}
+
+func TestScrapeMonitor(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for _, l := range monitorLogs {
+			for c, m := range l.metrics {
+				cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+				cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+			}
+		}
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	errorsColumns := []string{"hostname", "port", "time_start_us"}
+	for _, l := range monitorLogs {
+		columns := append([]string{"hostname", "port", "MAX(time_start_us)"}, l.columns...)
+		columns = append(columns, l.errorColumn)
+		rows := sqlmock.NewRows(columns)
+		errorsRows := sqlmock.NewRows(errorsColumns)
+		switch l.name {
+		case "ping":
+			rows.AddRow("10.91.142.80", "3306", "1538096142000000", "163", nil).
+				AddRow("10.91.142.82", "3306", "1538096142000100", "0", "timeout on creating new connection")
+			errorsRows.AddRow("10.91.142.82", "3306", "1538096140000100").
+				AddRow("10.91.142.82", "3306", "1538096141000100").
+				AddRow("10.91.142.82", "3306", "1538096142000100")
+		case "connect":
+			rows.AddRow("10.91.142.80", "3306", "1538096140000000", "2540", nil)
+		case "read_only":
+			rows.AddRow("10.91.142.80", "3306", "1538096141000000", "402", "0", nil).
+				AddRow("10.91.142.82", "3306", "1538096141000100", "0", nil, "timeout")
+			errorsRows.AddRow("10.91.142.82", "3306", "1538096141000100")
+		case "replication_lag":
+			rows.AddRow("10.91.142.80", "3306", "1538096141000000", "512", "7", nil)
+		}
+		mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(monitorLogLastQuery, strings.Join(l.columns, ", "), l.errorColumn, l.table))).WillReturnRows(rows)
+		mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(monitorLogErrorsQuery, l.table, l.errorColumn, l.errorColumn, 0))).WillReturnRows(errorsRows)
+	}
+
+	errs := newMonitorErrors()
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMonitor(db, ch, errs); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_monitor_ping_latency_us", prometheus.Labels{"endpoint": "10.91.142.80:3306"}, 163, dto.MetricType_GAUGE},
+		{"proxysql_monitor_ping_error", prometheus.Labels{"endpoint": "10.91.142.80:3306"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_monitor_ping_latency_us", prometheus.Labels{"endpoint": "10.91.142.82:3306"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_monitor_ping_error", prometheus.Labels{"endpoint": "10.91.142.82:3306"}, 1, dto.MetricType_GAUGE},
+
+		{"proxysql_monitor_connect_latency_us", prometheus.Labels{"endpoint": "10.91.142.80:3306"}, 2540, dto.MetricType_GAUGE},
+		{"proxysql_monitor_connect_error", prometheus.Labels{"endpoint": "10.91.142.80:3306"}, 0, dto.MetricType_GAUGE},
+
+		{"proxysql_monitor_read_only_latency_us", prometheus.Labels{"endpoint": "10.91.142.80:3306"}, 402, dto.MetricType_GAUGE},
+		{"proxysql_monitor_read_only", prometheus.Labels{"endpoint": "10.91.142.80:3306"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_monitor_read_only_error", prometheus.Labels{"endpoint": "10.91.142.80:3306"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_monitor_read_only_latency_us", prometheus.Labels{"endpoint": "10.91.142.82:3306"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_monitor_read_only_error", prometheus.Labels{"endpoint": "10.91.142.82:3306"}, 1, dto.MetricType_GAUGE},
+
+		{"proxysql_monitor_replication_lag_latency_us", prometheus.Labels{"endpoint": "10.91.142.80:3306"}, 512, dto.MetricType_GAUGE},
+		{"proxysql_monitor_replication_lag_seconds", prometheus.Labels{"endpoint": "10.91.142.80:3306"}, 7, dto.MetricType_GAUGE},
+		{"proxysql_monitor_replication_lag_error", prometheus.Labels{"endpoint": "10.91.142.80:3306"}, 0, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+
+		var errorsTotal []metricResult
+		for m := range ch {
+			errorsTotal = append(errorsTotal, *readMetric(m))
+		}
+		cv.So(errorsTotal, convey.ShouldHaveLength, 2)
+		cv.So(metricResult{"proxysql_monitor_errors_total", prometheus.Labels{"log": "ping", "endpoint": "10.91.142.82:3306"}, 3, dto.MetricType_COUNTER},
+			convey.ShouldBeIn, errorsTotal)
+		cv.So(metricResult{"proxysql_monitor_errors_total", prometheus.Labels{"log": "read_only", "endpoint": "10.91.142.82:3306"}, 1, dto.MetricType_COUNTER},
+			convey.ShouldBeIn, errorsTotal)
+	})
+
+	convey.Convey("Errors are counted once", t, func(cv convey.C) {
+		cv.So(errs.since, convey.ShouldResemble, map[monitorErrorsKey]int64{
+			{"ping", "10.91.142.82:3306"}:      1538096142000100,
+			{"read_only", "10.91.142.82:3306"}: 1538096141000100,
+		})
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMonitorError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	l := monitorLogs[0]
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(monitorLogLastQuery, strings.Join(l.columns, ", "), l.errorColumn, l.table))).WillReturnError(errors.New("error"))
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeMonitor(db, ch, newMonitorErrors())
+	assert.Error(t, err)
+}
+
+func TestScrapeMonitorLogErrorsConcurrent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	// the second scrape must see time_start_us of errors counted by the first one
+	l := monitorLogs[0]
+	errorsColumns := []string{"hostname", "port", "time_start_us"}
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(monitorLogErrorsQuery, l.table, l.errorColumn, l.errorColumn, 0))).
+		WillReturnRows(sqlmock.NewRows(errorsColumns).
+			AddRow("10.91.142.82", "3306", "1538096140000100").
+			AddRow("10.91.142.82", "3306", "1538096141000100").
+			AddRow("10.91.142.82", "3306", "1538096142000100"))
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(monitorLogErrorsQuery, l.table, l.errorColumn, l.errorColumn, 1538096142000100))).
+		WillReturnRows(sqlmock.NewRows(errorsColumns))
+
+	errs := newMonitorErrors()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, scrapeMonitorLogErrors(db, l, errs))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, float64(3), readMetric(errs.total.WithLabelValues(l.name, "10.91.142.82:3306")).value)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScrapeMonitorLogErrorsLate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	// failed check of the slow server is logged after the later check of the fast one
+	l := monitorLogs[0]
+	errorsColumns := []string{"hostname", "port", "time_start_us"}
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(monitorLogErrorsQuery, l.table, l.errorColumn, l.errorColumn, 0))).
+		WillReturnRows(sqlmock.NewRows(errorsColumns).
+			AddRow("10.91.142.80", "3306", "1538096140000000").
+			AddRow("10.91.142.82", "3306", "1538096142000000"))
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(monitorLogErrorsQuery, l.table, l.errorColumn, l.errorColumn, 1538096140000000))).
+		WillReturnRows(sqlmock.NewRows(errorsColumns).
+			AddRow("10.91.142.80", "3306", "1538096141000000").
+			AddRow("10.91.142.84", "3306", "1538096141500000").
+			AddRow("10.91.142.82", "3306", "1538096142000000"))
+
+	errs := newMonitorErrors()
+	assert.NoError(t, scrapeMonitorLogErrors(db, l, errs))
+	assert.NoError(t, scrapeMonitorLogErrors(db, l, errs))
+
+	assert.Equal(t, float64(2), readMetric(errs.total.WithLabelValues(l.name, "10.91.142.80:3306")).value)
+	assert.Equal(t, float64(1), readMetric(errs.total.WithLabelValues(l.name, "10.91.142.82:3306")).value)
+	assert.Equal(t, float64(1), readMetric(errs.total.WithLabelValues(l.name, "10.91.142.84:3306")).value)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScrapeRuntimeMySQLServers(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for c, m := range runtimeMySQLServersMetrics {
+			cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+			cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+		}
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"hostgroup_id", "hostname", "port", "status", "weight", "compression", "max_connections",
+		"max_replication_lag", "use_ssl", "max_latency_ms"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("0", "10.91.142.80", "3306", "ONLINE", "1000", "0", "500", "0", "1", "0").
+		AddRow("1", "10.91.142.82", "3306", "OFFLINE_SOFT", "1", "1", "1000", "10", "0", "200")
+	mock.ExpectQuery(sanitizeQuery(runtimeMySQLServersQuery)).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeRuntimeMySQLServers(db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_runtime_mysql_servers_info", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306", "status": "ONLINE"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_runtime_mysql_servers_weight", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 1000, dto.MetricType_GAUGE},
+		{"proxysql_runtime_mysql_servers_compression", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_runtime_mysql_servers_max_connections", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 500, dto.MetricType_GAUGE},
+		{"proxysql_runtime_mysql_servers_max_replication_lag", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_runtime_mysql_servers_use_ssl", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_runtime_mysql_servers_max_latency_ms", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 0, dto.MetricType_GAUGE},
+
+		{"proxysql_runtime_mysql_servers_info", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.82:3306", "status": "OFFLINE_SOFT"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_runtime_mysql_servers_weight", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.82:3306"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_runtime_mysql_servers_compression", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.82:3306"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_runtime_mysql_servers_max_connections", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.82:3306"}, 1000, dto.MetricType_GAUGE},
+		{"proxysql_runtime_mysql_servers_max_replication_lag", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.82:3306"}, 10, dto.MetricType_GAUGE},
+		{"proxysql_runtime_mysql_servers_use_ssl", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.82:3306"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_runtime_mysql_servers_max_latency_ms", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.82:3306"}, 200, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeRuntimeMySQLServersError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(runtimeMySQLServersQuery)).WillReturnError(errors.New("error"))
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeRuntimeMySQLServers(db, ch)
+	assert.Error(t, err)
+}
+
+func TestScrapeHostgroups(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(hostgroupsTables[0].query())).WillReturnRows(
+		sqlmock.NewRows([]string{"writer_hostgroup", "reader_hostgroup", "writers"}).
+			AddRow("10", "11", "1"))
+	mock.ExpectQuery(sanitizeQuery(hostgroupsTables[1].query())).WillReturnRows(
+		sqlmock.NewRows([]string{"writer_hostgroup", "backup_writer_hostgroup", "reader_hostgroup", "offline_hostgroup", "max_writers", "writers"}).
+			AddRow("20", "21", "22", "23", "1", "0"))
+	mock.ExpectQuery(sanitizeQuery(hostgroupsTables[2].query())).WillReturnError(errors.New("no such table: runtime_mysql_galera_hostgroups"))
+	mock.ExpectQuery(sanitizeQuery(hostgroupsTables[3].query())).WillReturnRows(
+		sqlmock.NewRows([]string{"writer_hostgroup", "reader_hostgroup", "writers"}).
+			AddRow("40", "41", "1"))
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeHostgroups(db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_hostgroups_role_info", prometheus.Labels{"hostgroup": "10", "role": "writer", "cluster_type": "replication", "writer_hostgroup": "10"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_hostgroups_role_info", prometheus.Labels{"hostgroup": "11", "role": "reader", "cluster_type": "replication", "writer_hostgroup": "10"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_hostgroups_writers", prometheus.Labels{"cluster_type": "replication", "writer_hostgroup": "10"}, 1, dto.MetricType_GAUGE},
+
+		{"proxysql_hostgroups_role_info", prometheus.Labels{"hostgroup": "20", "role": "writer", "cluster_type": "group_replication", "writer_hostgroup": "20"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_hostgroups_role_info", prometheus.Labels{"hostgroup": "21", "role": "backup_writer", "cluster_type": "group_replication", "writer_hostgroup": "20"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_hostgroups_role_info", prometheus.Labels{"hostgroup": "22", "role": "reader", "cluster_type": "group_replication", "writer_hostgroup": "20"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_hostgroups_role_info", prometheus.Labels{"hostgroup": "23", "role": "offline", "cluster_type": "group_replication", "writer_hostgroup": "20"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_hostgroups_writers", prometheus.Labels{"cluster_type": "group_replication", "writer_hostgroup": "20"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_hostgroups_max_writers", prometheus.Labels{"cluster_type": "group_replication", "writer_hostgroup": "20"}, 1, dto.MetricType_GAUGE},
+
+		{"proxysql_hostgroups_role_info", prometheus.Labels{"hostgroup": "40", "role": "writer", "cluster_type": "aws_aurora", "writer_hostgroup": "40"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_hostgroups_role_info", prometheus.Labels{"hostgroup": "41", "role": "reader", "cluster_type": "aws_aurora", "writer_hostgroup": "40"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_hostgroups_writers", prometheus.Labels{"cluster_type": "aws_aurora", "writer_hostgroup": "40"}, 1, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		// drain the channel, so all queries are finished before checking expectations
+		var got []metricResult
+		for m := range ch {
+			got = append(got, *readMetric(m))
+		}
+		cv.So(got, convey.ShouldResemble, counterExpected)
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeHostgroupsError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(hostgroupsTables[0].query())).WillReturnError(errors.New("error"))
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeHostgroups(db, ch)
+	assert.Error(t, err)
+}
+
+func TestScrapeProxySQLServers(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for _, metrics := range []map[string]*metric{proxySQLServersStatusMetrics, proxySQLServersMetricsMetrics} {
+			for c, m := range metrics {
+				cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+				cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+			}
+		}
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"hostname", "port", "hostname", "port", "weight", "master", "global_version", "check_age_us",
+		"ping_time_us", "checks_OK", "checks_ERR"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("proxysql-1", "6032", "proxysql-1", "6032", "0", "TRUE", "3", "1000", "250", "100", "1")
+	mock.ExpectQuery(sanitizeQuery(proxySQLServersStatusQuery)).WillReturnRows(rows)
+
+	columns = []string{"hostname", "port", "hostname", "port", "weight", "comment", "response_time_ms", "Uptime_s",
+		"last_check_ms", "Queries", "Client_Connections_connected", "Client_Connections_created"}
+	rows = sqlmock.NewRows(columns).
+		AddRow("proxysql-1", "6032", "proxysql-1", "6032", "0", "node 1", "1", "8000", "900", "1000000", "64", "5000")
+	mock.ExpectQuery(sanitizeQuery(proxySQLServersMetricsQuery)).WillReturnRows(rows)
+
+	columns = []string{"hostname", "port", "name", "version", "epoch", "checksum", "diff_check"}
+	rows = sqlmock.NewRows(columns).
+		AddRow("proxysql-1", "6032", "mysql_servers", "3", "1538092542", "0x4A5C8E0B7AA0E4E1", "0").
+		AddRow("proxysql-2", "6032", "mysql_servers", "2", "1538092500", "0x1D0A6B6E3C0B4BF0", "12").
+		AddRow("proxysql-3", "6032", "mysql_servers", "0", "0", nil, "0").
+		AddRow("proxysql-1", "6032", "mysql_users", "1", "1538090000", "0x0A1AD06A20CAE2D9", "0")
+	mock.ExpectQuery(sanitizeQuery(proxySQLServersChecksumsQuery)).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeProxySQLServers(db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_cluster_status_weight", prometheus.Labels{"peer": "proxysql-1:6032"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_cluster_status_global_version", prometheus.Labels{"peer": "proxysql-1:6032"}, 3, dto.MetricType_GAUGE},
+		{"proxysql_cluster_status_check_age_us", prometheus.Labels{"peer": "proxysql-1:6032"}, 1000, dto.MetricType_GAUGE},
+		{"proxysql_cluster_status_ping_time_us", prometheus.Labels{"peer": "proxysql-1:6032"}, 250, dto.MetricType_GAUGE},
+		{"proxysql_cluster_status_checks_ok", prometheus.Labels{"peer": "proxysql-1:6032"}, 100, dto.MetricType_COUNTER},
+		{"proxysql_cluster_status_checks_err", prometheus.Labels{"peer": "proxysql-1:6032"}, 1, dto.MetricType_COUNTER},
+
+		{"proxysql_cluster_metrics_weight", prometheus.Labels{"peer": "proxysql-1:6032"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_cluster_metrics_response_time_ms", prometheus.Labels{"peer": "proxysql-1:6032"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_cluster_metrics_uptime_s", prometheus.Labels{"peer": "proxysql-1:6032"}, 8000, dto.MetricType_COUNTER},
+		{"proxysql_cluster_metrics_last_check_ms", prometheus.Labels{"peer": "proxysql-1:6032"}, 900, dto.MetricType_GAUGE},
+		{"proxysql_cluster_metrics_queries", prometheus.Labels{"peer": "proxysql-1:6032"}, 1000000, dto.MetricType_COUNTER},
+		{"proxysql_cluster_metrics_client_connections_connected", prometheus.Labels{"peer": "proxysql-1:6032"}, 64, dto.MetricType_GAUGE},
+		{"proxysql_cluster_metrics_client_connections_created", prometheus.Labels{"peer": "proxysql-1:6032"}, 5000, dto.MetricType_COUNTER},
+
+		{"proxysql_cluster_checksums_version", prometheus.Labels{"peer": "proxysql-1:6032", "module": "mysql_servers"}, 3, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksums_epoch", prometheus.Labels{"peer": "proxysql-1:6032", "module": "mysql_servers"}, 1538092542, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksums_diff_check", prometheus.Labels{"peer": "proxysql-1:6032", "module": "mysql_servers"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksums_version", prometheus.Labels{"peer": "proxysql-2:6032", "module": "mysql_servers"}, 2, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksums_epoch", prometheus.Labels{"peer": "proxysql-2:6032", "module": "mysql_servers"}, 1538092500, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksums_diff_check", prometheus.Labels{"peer": "proxysql-2:6032", "module": "mysql_servers"}, 12, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksums_version", prometheus.Labels{"peer": "proxysql-3:6032", "module": "mysql_servers"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksums_epoch", prometheus.Labels{"peer": "proxysql-3:6032", "module": "mysql_servers"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksums_diff_check", prometheus.Labels{"peer": "proxysql-3:6032", "module": "mysql_servers"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksums_version", prometheus.Labels{"peer": "proxysql-1:6032", "module": "mysql_users"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksums_epoch", prometheus.Labels{"peer": "proxysql-1:6032", "module": "mysql_users"}, 1538090000, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksums_diff_check", prometheus.Labels{"peer": "proxysql-1:6032", "module": "mysql_users"}, 0, dto.MetricType_GAUGE},
+
+		{"proxysql_cluster_checksums_distinct", prometheus.Labels{"module": "mysql_servers"}, 2, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksums_distinct", prometheus.Labels{"module": "mysql_users"}, 1, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeProxySQLServersError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(proxySQLServersStatusQuery)).WillReturnError(errors.New("error"))
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeProxySQLServers(db, ch)
+	assert.Error(t, err)
+}
+
+func TestScrapeMySQLUsers(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for c, m := range mySQLUsersMetrics {
+			cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+			cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+		}
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"username", "frontend_connections", "frontend_max_connections"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("app", "80", "100").
+		AddRow("disabled", "0", "0")
+	mock.ExpectQuery(sanitizeQuery(mySQLUsersQuery)).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLUsers(db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_mysql_users_frontend_connections", prometheus.Labels{"username": "app"}, 80, dto.MetricType_GAUGE},
+		{"proxysql_mysql_users_frontend_max_connections", prometheus.Labels{"username": "app"}, 100, dto.MetricType_GAUGE},
+		{"proxysql_mysql_users_frontend_connections_ratio", prometheus.Labels{"username": "app"}, 0.8, dto.MetricType_GAUGE},
+		{"proxysql_mysql_users_frontend_connections", prometheus.Labels{"username": "disabled"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_mysql_users_frontend_max_connections", prometheus.Labels{"username": "disabled"}, 0, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLUsersError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(mySQLUsersQuery)).WillReturnError(errors.New("error"))
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeMySQLUsers(db, ch)
+	assert.Error(t, err)
+}
+
+func TestScrapeMySQLQueryRules(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for c, m := range mySQLQueryRulesMetrics {
+			cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+			cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+		}
+	})
+
+	for _, withComment := range []bool{false, true} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error opening a stub database connection: %s", err)
+		}
+		defer db.Close()
+
+		columns := []string{"rule_id", "hits", "active", "destination_hostgroup", "comment"}
+		rows := sqlmock.NewRows(columns).
+			AddRow("1", "2000", "1", "10", "writes").
+			AddRow("2", "0", "1", nil, nil)
+		mock.ExpectQuery(sanitizeQuery(mySQLQueryRulesQuery)).WillReturnRows(rows)
+
+		ch := make(chan prometheus.Metric)
+		go func() {
+			if err = scrapeMySQLQueryRules(db, ch, withComment); err != nil {
+				t.Errorf("error calling function on test: %s", err)
+			}
+			close(ch)
+		}()
+
+		counterExpected := []metricResult{
+			{"proxysql_query_rules_hits", prometheus.Labels{"rule_id": "1", "active": "1", "destination_hostgroup": "10"}, 2000, dto.MetricType_COUNTER},
+			{"proxysql_query_rules_hits", prometheus.Labels{"rule_id": "2", "active": "1", "destination_hostgroup": ""}, 0, dto.MetricType_COUNTER},
+		}
+		if withComment {
+			counterExpected[0].labels["comment"] = "writes"
+			counterExpected[1].labels["comment"] = ""
+		}
+		convey.Convey(fmt.Sprintf("Metrics comparison with comment %v", withComment), t, convey.FailureContinues, func(cv convey.C) {
+			for _, expect := range counterExpected {
+				got := *readMetric(<-ch)
+				cv.So(got, convey.ShouldResemble, expect)
+			}
+		})
+
+		// Ensure all SQL queries were executed
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	}
+}
+
+func TestScrapeMySQLQueryRulesError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(mySQLQueryRulesQuery)).WillReturnError(errors.New("error"))
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeMySQLQueryRules(db, ch, false)
+	assert.Error(t, err)
+}
+
+func TestScrapeMySQLFreeConnections(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"hostgroup", "srv_host", "srv_port", "autocommit", "init_connect", "time_zone", "sql_mode", "idle_ms", "mysql_info"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("0", "10.91.142.80", "3306", "1", "", "", "", "500", `{"charset":33,"thread_id":12}`).
+		AddRow("0", "10.91.142.80", "3306", "0", nil, "+00:00", nil, "45000", `{"charset":45,"thread_id":13}`).
+		AddRow("0", "10.91.142.80", "3306", "-1", "", "", "", "7200000", `{"charset":33,"thread_id":14}`).
+		AddRow("1", "10.91.142.82", "3306", "1", "", "", "", "1000", "")
+	mock.ExpectQuery(sanitizeQuery(mySQLFreeConnectionsQuery)).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLFreeConnections(db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	readHistogram := func(m prometheus.Metric) (uint64, float64, map[float64]uint64) {
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			t.Fatal(err)
+		}
+		buckets := make(map[float64]uint64)
+		for _, b := range pb.GetHistogram().GetBucket() {
+			buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+		}
+		return pb.GetHistogram().GetSampleCount(), pb.GetHistogram().GetSampleSum(), buckets
+	}
+
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		labels := prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}
+		cv.So(*readMetric(<-ch), convey.ShouldResemble, metricResult{"proxysql_free_connections_count", labels, 3, dto.MetricType_GAUGE})
+		count, sum, buckets := readHistogram(<-ch)
+		cv.So(count, convey.ShouldEqual, 3)
+		cv.So(sum, convey.ShouldEqual, 7245.5)
+		cv.So(buckets, convey.ShouldResemble, map[float64]uint64{1: 1, 5: 1, 10: 1, 30: 1, 60: 2, 300: 2, 600: 2, 1800: 2, 3600: 2})
+		cv.So(*readMetric(<-ch), convey.ShouldResemble, metricResult{"proxysql_free_connections_autocommit_off", labels, 1, dto.MetricType_GAUGE})
+		cv.So(*readMetric(<-ch), convey.ShouldResemble, metricResult{"proxysql_free_connections_session_variables", labels, 1, dto.MetricType_GAUGE})
+		cv.So(*readMetric(<-ch), convey.ShouldResemble, metricResult{"proxysql_free_connections_charset", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306", "charset": "33"}, 2, dto.MetricType_GAUGE})
+		cv.So(*readMetric(<-ch), convey.ShouldResemble, metricResult{"proxysql_free_connections_charset", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306", "charset": "45"}, 1, dto.MetricType_GAUGE})
+
+		labels = prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.82:3306"}
+		cv.So(*readMetric(<-ch), convey.ShouldResemble, metricResult{"proxysql_free_connections_count", labels, 1, dto.MetricType_GAUGE})
+		count, sum, buckets = readHistogram(<-ch)
+		cv.So(count, convey.ShouldEqual, 1)
+		cv.So(sum, convey.ShouldEqual, 1)
+		cv.So(buckets[1], convey.ShouldEqual, 1)
+		cv.So(*readMetric(<-ch), convey.ShouldResemble, metricResult{"proxysql_free_connections_autocommit_off", labels, 0, dto.MetricType_GAUGE})
+		cv.So(*readMetric(<-ch), convey.ShouldResemble, metricResult{"proxysql_free_connections_session_variables", labels, 0, dto.MetricType_GAUGE})
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLFreeConnectionsError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(mySQLFreeConnectionsQuery)).WillReturnError(errors.New("error"))
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeMySQLFreeConnections(db, ch)
+	assert.Error(t, err)
+}
+
+func TestScrapePreparedStatements(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for _, metrics := range []map[string]*metric{preparedStatementsMetrics, preparedStatementsTopMetrics} {
+			for c, m := range metrics {
+				cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+				cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+			}
+		}
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"schemaname", "username", "count", "ref_count_client", "ref_count_server"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("sbtest", "app", "1500", "4200", "310")
+	mock.ExpectQuery(sanitizeQuery(preparedStatementsQuery)).WillReturnRows(rows)
+
+	columns = []string{"schemaname", "username", "digest", "ref_count_client", "ref_count_server"}
+	rows = sqlmock.NewRows(columns).
+		AddRow("sbtest", "app", "0x5A1F3C2E7D5EE2A6", "3000", "12")
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(preparedStatementsTopQuery, 1))).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapePreparedStatements(db, ch, 1); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_prepared_statements_count", prometheus.Labels{"schemaname": "sbtest", "username": "app"}, 1500, dto.MetricType_GAUGE},
+		{"proxysql_prepared_statements_ref_count_client", prometheus.Labels{"schemaname": "sbtest", "username": "app"}, 4200, dto.MetricType_GAUGE},
+		{"proxysql_prepared_statements_ref_count_server", prometheus.Labels{"schemaname": "sbtest", "username": "app"}, 310, dto.MetricType_GAUGE},
+		{"proxysql_prepared_statements_top_ref_count_client", prometheus.Labels{"schemaname": "sbtest", "username": "app", "digest": "0x5A1F3C2E7D5EE2A6"}, 3000, dto.MetricType_GAUGE},
+		{"proxysql_prepared_statements_top_ref_count_server", prometheus.Labels{"schemaname": "sbtest", "username": "app", "digest": "0x5A1F3C2E7D5EE2A6"}, 12, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapePreparedStatementsError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(preparedStatementsQuery)).WillReturnError(errors.New("error"))
+
+	ch := make(chan prometheus.Metric)
+	err = scrapePreparedStatements(db, ch, 10)
+	assert.Error(t, err)
+}
+
+func TestScrapeGTIDExecuted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	uuid := "3E11FA47-71CA-11E1-9E33-C80AA9429562"
+	columns := []string{"hostname", "port", "gtid_executed", "events"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("10.91.142.80", "3306", uuid+":1-1000", "1000").
+		AddRow("10.91.142.82", "3306", uuid+":1-990", "990").
+		AddRow("10.91.142.88", "3306", uuid+":1-1000", "1000")
+	mock.ExpectQuery(sanitizeQuery(gtidExecutedQuery)).WillReturnRows(rows)
+
+	columns = []string{"writer_hostgroup", "writer", "hostname", "port"}
+	rows = sqlmock.NewRows(columns).
+		AddRow("0", "1", "10.91.142.80", "3306").
+		AddRow("0", "0", "10.91.142.82", "3306").
+		AddRow("0", "0", "10.91.142.88", "3306").
+		AddRow("0", "0", "10.91.142.89", "3306")
+	mock.ExpectQuery(sanitizeQuery(gtidReplicationServersQuery)).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeGTIDExecuted(db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_gtid_executed_events", prometheus.Labels{"endpoint": "10.91.142.80:3306"}, 1000, dto.MetricType_COUNTER},
+		{"proxysql_gtid_executed_events", prometheus.Labels{"endpoint": "10.91.142.82:3306"}, 990, dto.MetricType_COUNTER},
+		{"proxysql_gtid_executed_events", prometheus.Labels{"endpoint": "10.91.142.88:3306"}, 1000, dto.MetricType_COUNTER},
+		{"proxysql_gtid_executed_lag_transactions", prometheus.Labels{"endpoint": "10.91.142.82:3306", "writer_hostgroup": "0"}, 10, dto.MetricType_GAUGE},
+		{"proxysql_gtid_executed_lag_transactions", prometheus.Labels{"endpoint": "10.91.142.88:3306", "writer_hostgroup": "0"}, 0, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+		_, ok := <-ch
+		cv.So(ok, convey.ShouldBeFalse)
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeGTIDExecutedError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(gtidExecutedQuery)).WillReturnError(errors.New("error"))
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeGTIDExecuted(db, ch)
+	assert.Error(t, err)
+}
+
+func TestScrapeStatsHistory(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for _, table := range statsHistoryTables {
+			for c, m := range table.metrics {
+				cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+				cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+			}
+		}
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"timestamp", "tms_utime", "tms_stime"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("1546004400", "1200", "300")
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(statsHistoryQuery, "system_cpu"))).WillReturnRows(rows)
+
+	columns = []string{"timestamp", "allocated", "resident"}
+	rows = sqlmock.NewRows(columns).
+		AddRow("1546004400", "10485760", "20971520")
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(statsHistoryQuery, "system_memory"))).WillReturnRows(rows)
+
+	columns = []string{"timestamp", "Client_Connections_connected", "Questions"}
+	rows = sqlmock.NewRows(columns).
+		AddRow("1546004400", "12", "4567")
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(statsHistoryQuery, "mysql_connections"))).WillReturnRows(rows)
+
+	columns = []string{"timestamp", "MyHGM_myconnpoll_get", "MyHGM_myconnpoll_new"}
+	rows = sqlmock.NewRows(columns).
+		AddRow("1546004400", "321", "7")
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(statsHistoryQuery, "myhgm_connections"))).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeStatsHistory(db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_history_system_cpu_tms_utime", prometheus.Labels{}, 1200, dto.MetricType_COUNTER},
+		{"proxysql_history_system_cpu_tms_stime", prometheus.Labels{}, 300, dto.MetricType_COUNTER},
+		{"proxysql_history_system_memory_allocated", prometheus.Labels{}, 10485760, dto.MetricType_GAUGE},
+		{"proxysql_history_system_memory_resident", prometheus.Labels{}, 20971520, dto.MetricType_GAUGE},
+		{"proxysql_history_mysql_connections_client_connections_connected", prometheus.Labels{}, 12, dto.MetricType_GAUGE},
+		{"proxysql_history_mysql_connections_questions", prometheus.Labels{}, 4567, dto.MetricType_COUNTER},
+		{"proxysql_history_myhgm_connections_myhgm_myconnpoll_get", prometheus.Labels{}, 321, dto.MetricType_COUNTER},
+		{"proxysql_history_myhgm_connections_myhgm_myconnpoll_new", prometheus.Labels{}, 7, dto.MetricType_UNTYPED},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+		_, ok := <-ch
+		cv.So(ok, convey.ShouldBeFalse)
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeStatsHistoryError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(statsHistoryQuery, "system_cpu"))).WillReturnError(errors.New("error"))
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeStatsHistory(db, ch)
+	assert.Error(t, err)
+}
+
+func TestScrapeGlobalVariables(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for c, m := range globalVariablesMetrics {
 			cv.So(c, convey.ShouldEqual, strings.ToLower(c))
 			cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
 		}
@@ -93,37 +1571,40 @@ func TestScrapeMySQLGlobal(t *testing.T) {
 	}
 	defer db.Close()
 
-	columns := []string{"Variable_Name", "Variable_Value"}
+	columns := []string{"variable_name", "variable_value"}
 	rows := sqlmock.NewRows(columns).
-		AddRow("Active_Transactions", "3").
-		AddRow("Backend_query_time_nsec", "76355784684851").
-		AddRow("Client_Connections_aborted", "0").
-		AddRow("Client_Connections_connected", "64").
-		AddRow("Client_Connections_created", "1087931").
-		AddRow("Servers_table_version", "2019470")
-	mock.ExpectQuery(mySQLGlobalQuery).WillReturnRows(rows)
+		AddRow("mysql-max_connections", "2048").
+		AddRow("mysql-threads", "4").
+		AddRow("mysql-monitor_ping_interval", "10000").
+		AddRow("mysql-monitor_enabled", "true").
+		AddRow("mysql-monitor_password", "monitor").
+		AddRow("mysql-default_charset", "utf8").
+		AddRow("admin-admin_credentials", "admin:admin").
+		AddRow("admin-refresh_interval", "2000")
+	mock.ExpectQuery(sanitizeQuery(globalVariablesQuery)).WillReturnRows(rows)
 
 	ch := make(chan prometheus.Metric)
 	go func() {
-		if err = scrapeMySQLGlobal(db, ch); err != nil {
+		if err = scrapeGlobalVariables(db, ch, regexp.MustCompile("^mysql-"), regexp.MustCompile("password|credentials")); err != nil {
 			t.Errorf("error calling function on test: %s", err)
 		}
 		close(ch)
 	}()
 
 	counterExpected := []metricResult{
-		{"proxysql_mysql_status_active_transactions", prometheus.Labels{}, 3, dto.MetricType_GAUGE},
-		{"proxysql_mysql_status_backend_query_time_nsec", prometheus.Labels{}, 76355784684851, dto.MetricType_UNTYPED},
-		{"proxysql_mysql_status_client_connections_aborted", prometheus.Labels{}, 0, dto.MetricType_COUNTER},
-		{"proxysql_mysql_status_client_connections_connected", prometheus.Labels{}, 64, dto.MetricType_GAUGE},
-		{"proxysql_mysql_status_client_connections_created", prometheus.Labels{}, 1087931, dto.MetricType_COUNTER},
-		{"proxysql_mysql_status_servers_table_version", prometheus.Labels{}, 2019470, dto.MetricType_UNTYPED},
+		{"proxysql_global_variable", prometheus.Labels{"name": "mysql-max_connections"}, 2048, dto.MetricType_GAUGE},
+		{"proxysql_global_variable", prometheus.Labels{"name": "mysql-threads"}, 4, dto.MetricType_GAUGE},
+		{"proxysql_global_variable", prometheus.Labels{"name": "mysql-monitor_ping_interval"}, 10000, dto.MetricType_GAUGE},
+		{"proxysql_global_variable", prometheus.Labels{"name": "mysql-monitor_enabled"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_global_variable_info", prometheus.Labels{"name": "mysql-default_charset", "value": "utf8"}, 1, dto.MetricType_GAUGE},
 	}
 	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
 		for _, expect := range counterExpected {
 			got := *readMetric(<-ch)
 			cv.So(got, convey.ShouldResemble, expect)
 		}
+		_, ok := <-ch
+		cv.So(ok, convey.ShouldBeFalse)
 	})
 
 	// Ensure all SQL queries were executed
@@ -132,43 +1613,23 @@ func TestScrapeMySQLGlobal(t *testing.T) {
 	}
 }
 
-func TestScrapeMySQLGlobalError(t *testing.T) {
-	db1, mock1, err1 := sqlmock.New()
-	if err1 != nil {
-		t.Fatalf("error opening a stub database connection: %s", err1)
-	}
-	defer db1.Close()
-
-	mock1.ExpectQuery(mySQLGlobalQuery).WillReturnError(errors.New("an error"))
-	ch1 := make(chan prometheus.Metric)
-
-	go func() {
-		scrapeMySQLGlobal(db1, ch1)
-		close(ch1)
-	}()
-
-	db2, mock2, err2 := sqlmock.New()
-	if err2 != nil {
-		t.Fatalf("error opening a stub database connection: %s", err2)
+func TestScrapeGlobalVariablesError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
 	}
-	defer db2.Close()
-
-	columns := []string{"Variable_Name", "Variable_Value"}
-	rows := sqlmock.NewRows(columns).AddRow("Active_Transactions", "3")
-	mock2.ExpectQuery(sanitizeQuery(mySQLGlobalQuery)).WillReturnRows(rows)
+	defer db.Close()
 
-	ch2 := make(chan prometheus.Metric)
-	go func() {
-		scrapeMySQLGlobal(db2, ch2)
-		close(ch2)
-	}()
+	mock.ExpectQuery(sanitizeQuery(globalVariablesQuery)).WillReturnError(errors.New("error"))
 
-	_ = *readMetric(<-ch2)
+	ch := make(chan prometheus.Metric)
+	err = scrapeGlobalVariables(db, ch, nil, nil)
+	assert.Error(t, err)
 }
 
-func TestScrapeMySQLConnectionPool(t *testing.T) {
+func TestScrapeRuntimeChecksums(t *testing.T) {
 	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
-		for c, m := range mySQLconnectionPoolMetrics {
+		for c, m := range runtimeChecksumsMetrics {
 			cv.So(c, convey.ShouldEqual, strings.ToLower(c))
 			cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
 		}
@@ -180,69 +1641,58 @@ func TestScrapeMySQLConnectionPool(t *testing.T) {
 	}
 	defer db.Close()
 
-	columns := []string{"hostgroup", "srv_host", "srv_port", "status", "ConnUsed", "ConnFree", "ConnOK", "ConnERR",
-		"Queries", "Bytes_data_sent", "Bytes_data_recv", "Latency_us"}
+	columns := []string{"name", "version", "epoch", "checksum"}
 	rows := sqlmock.NewRows(columns).
-		AddRow("0", "10.91.142.80", "3306", "ONLINE", "0", "45", "1895677", "46", "197941647", "10984550806", "321063484988", "163").
-		AddRow("0", "10.91.142.82", "3306", "SHUNNED", "0", "97", "39859", "0", "386686994", "21643682247", "641406745151", "255").
-		AddRow("1", "10.91.142.88", "3306", "OFFLINE_SOFT", "0", "18", "31471", "6391", "255993467", "14327840185", "420795691329", "283").
-		AddRow("2", "10.91.142.89", "3306", "OFFLINE_HARD", "0", "18", "31471", "6391", "255993467", "14327840185", "420795691329", "283")
-	mock.ExpectQuery(sanitizeQuery(mySQLconnectionPoolQuery)).WillReturnRows(rows)
-
+		AddRow("mysql_query_rules", "1", "1546004400", "0x8CB14DC08A2E9E60").
+		AddRow("mysql_servers", "1", "1546004400", "0x2F5E7C1B0F7A3D11")
+	mock.ExpectQuery(sanitizeQuery(runtimeChecksumsQuery)).WillReturnRows(rows)
+	rows = sqlmock.NewRows(columns).
+		AddRow("mysql_query_rules", "2", "1546004460", "0x6E2AB9C4D1F08A77").
+		AddRow("mysql_servers", "1", "1546004400", "0x2F5E7C1B0F7A3D11")
+	mock.ExpectQuery(sanitizeQuery(runtimeChecksumsQuery)).WillReturnRows(rows)
+
+	changes := newChecksumChanges()
 	ch := make(chan prometheus.Metric)
 	go func() {
-		if err = scrapeMySQLConnectionPool(db, ch); err != nil {
+		if err = scrapeRuntimeChecksums(db, ch, changes); err != nil {
 			t.Errorf("error calling function on test: %s", err)
 		}
 		close(ch)
 	}()
+	for range ch {
+	}
 
-	counterExpected := []metricResult{
-		{"proxysql_connection_pool_status", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 1, dto.MetricType_GAUGE},
-		{"proxysql_connection_pool_conn_used", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 0, dto.MetricType_GAUGE},
-		{"proxysql_connection_pool_conn_free", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 45, dto.MetricType_GAUGE},
-		{"proxysql_connection_pool_conn_ok", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 1895677, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_conn_err", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 46, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_queries", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 197941647, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_bytes_data_sent", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 10984550806, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_bytes_data_recv", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 321063484988, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_latency_us", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.80:3306"}, 163, dto.MetricType_GAUGE},
-
-		{"proxysql_connection_pool_status", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 2, dto.MetricType_GAUGE},
-		{"proxysql_connection_pool_conn_used", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 0, dto.MetricType_GAUGE},
-		{"proxysql_connection_pool_conn_free", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 97, dto.MetricType_GAUGE},
-		{"proxysql_connection_pool_conn_ok", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 39859, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_conn_err", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 0, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_queries", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 386686994, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_bytes_data_sent", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 21643682247, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_bytes_data_recv", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 641406745151, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_latency_us", prometheus.Labels{"hostgroup": "0", "endpoint": "10.91.142.82:3306"}, 255, dto.MetricType_GAUGE},
-
-		{"proxysql_connection_pool_status", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 3, dto.MetricType_GAUGE},
-		{"proxysql_connection_pool_conn_used", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 0, dto.MetricType_GAUGE},
-		{"proxysql_connection_pool_conn_free", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 18, dto.MetricType_GAUGE},
-		{"proxysql_connection_pool_conn_ok", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 31471, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_conn_err", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 6391, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_queries", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 255993467, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_bytes_data_sent", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 14327840185, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_bytes_data_recv", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 420795691329, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_latency_us", prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.88:3306"}, 283, dto.MetricType_GAUGE},
+	ch = make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeRuntimeChecksums(db, ch, changes); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
 
-		{"proxysql_connection_pool_status", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 4, dto.MetricType_GAUGE},
-		{"proxysql_connection_pool_conn_used", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 0, dto.MetricType_GAUGE},
-		{"proxysql_connection_pool_conn_free", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 18, dto.MetricType_GAUGE},
-		{"proxysql_connection_pool_conn_ok", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 31471, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_conn_err", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 6391, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_queries", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 255993467, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_bytes_data_sent", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 14327840185, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_bytes_data_recv", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 420795691329, dto.MetricType_COUNTER},
-		{"proxysql_connection_pool_latency_us", prometheus.Labels{"hostgroup": "2", "endpoint": "10.91.142.89:3306"}, 283, dto.MetricType_GAUGE},
+	counterExpected := []metricResult{
+		{"proxysql_runtime_checksums_version", prometheus.Labels{"module": "mysql_query_rules"}, 2, dto.MetricType_GAUGE},
+		{"proxysql_runtime_checksums_epoch", prometheus.Labels{"module": "mysql_query_rules"}, 1546004460, dto.MetricType_GAUGE},
+		{"proxysql_runtime_checksums_info", prometheus.Labels{"module": "mysql_query_rules", "checksum": "0x6E2AB9C4D1F08A77"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_runtime_checksums_version", prometheus.Labels{"module": "mysql_servers"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_runtime_checksums_epoch", prometheus.Labels{"module": "mysql_servers"}, 1546004400, dto.MetricType_GAUGE},
+		{"proxysql_runtime_checksums_info", prometheus.Labels{"module": "mysql_servers", "checksum": "0x2F5E7C1B0F7A3D11"}, 1, dto.MetricType_GAUGE},
 	}
 	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
 		for _, expect := range counterExpected {
 			got := *readMetric(<-ch)
 			cv.So(got, convey.ShouldResemble, expect)
 		}
+
+		var changesTotal []metricResult
+		for m := range ch {
+			changesTotal = append(changesTotal, *readMetric(m))
+		}
+		cv.So(changesTotal, convey.ShouldHaveLength, 2)
+		cv.So(metricResult{"proxysql_runtime_checksums_changes_total", prometheus.Labels{"module": "mysql_query_rules"}, 1, dto.MetricType_COUNTER},
+			convey.ShouldBeIn, changesTotal)
+		cv.So(metricResult{"proxysql_runtime_checksums_changes_total", prometheus.Labels{"module": "mysql_servers"}, 0, dto.MetricType_COUNTER},
+			convey.ShouldBeIn, changesTotal)
 	})
 
 	// Ensure all SQL queries were executed
@@ -251,47 +1701,126 @@ func TestScrapeMySQLConnectionPool(t *testing.T) {
 	}
 }
 
-func TestScrapeMySQLConnectionPoolError(t *testing.T) {
-	db1, mock1, err1 := sqlmock.New()
-	if err1 != nil {
-		t.Fatalf("error opening a stub database connection: %s", err1)
+func TestScrapeRuntimeChecksumsError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
 	}
-	defer db1.Close()
+	defer db.Close()
 
-	mock1.ExpectQuery(mySQLconnectionPoolQuery).WillReturnError(errors.New("an error"))
-	ch1 := make(chan prometheus.Metric)
+	mock.ExpectQuery(sanitizeQuery(runtimeChecksumsQuery)).WillReturnError(errors.New("error"))
 
-	go func() {
-		scrapeMySQLConnectionPool(db1, ch1)
-		close(ch1)
-	}()
+	ch := make(chan prometheus.Metric)
+	err = scrapeRuntimeChecksums(db, ch, newChecksumChanges())
+	assert.Error(t, err)
+}
 
-	mySQLconnectionPoolMetrics = map[string]*metric{
-		"hostgroup": {},
-		"latency_us": {"latency_us", prometheus.GaugeValue,
-			"The currently ping time in microseconds, as reported from Monitor."},
-		"latency_ms": {"latency_us", prometheus.GaugeValue,
-			"The currently ping time in microseconds, as reported from Monitor."},
+func TestScrapeRuntimeChecksumsConcurrent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
 	}
+	defer db.Close()
 
-	db2, mock2, err2 := sqlmock.New()
-	if err2 != nil {
-		t.Fatalf("error opening a stub database connection: %s", err2)
+	// the second scrape must apply its checksum after the first one
+	columns := []string{"name", "version", "epoch", "checksum"}
+	mock.ExpectQuery(sanitizeQuery(runtimeChecksumsQuery)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("mysql_query_rules", "1", "1546004400", "0x8CB14DC08A2E9E60"))
+	mock.ExpectQuery(sanitizeQuery(runtimeChecksumsQuery)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("mysql_query_rules", "2", "1546004460", "0x6E2AB9C4D1F08A77"))
+
+	changes := newChecksumChanges()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ch := make(chan prometheus.Metric)
+			go func() {
+				for range ch {
+				}
+			}()
+			assert.NoError(t, scrapeRuntimeChecksums(db, ch, changes))
+			close(ch)
+		}()
 	}
-	defer db2.Close()
+	wg.Wait()
 
-	columns := []string{"hostgroup", "srv_host", "srv_port", "status", "ConnUsed", "ConnFree", "ConnOK", "ConnERR",
-		"Queries", "Bytes_data_sent", "Bytes_data_recv", "Latency_us"}
-	rows := sqlmock.NewRows(columns).AddRow("0", "10.91.142.80", "3306", "ONLINE", "0", "45", "1895677", "46", "197941647", "10984550806", "321063484988", "163")
-	mock2.ExpectQuery(sanitizeQuery(mySQLconnectionPoolQuery)).WillReturnRows(rows)
+	assert.Equal(t, float64(1), readMetric(changes.total.WithLabelValues("mysql_query_rules")).value)
+	assert.Equal(t, "0x6E2AB9C4D1F08A77", changes.last["mysql_query_rules"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	ch2 := make(chan prometheus.Metric)
+func TestScrapeClientHostCache(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for _, metrics := range []map[string]*metric{clientHostCacheMetrics, clientHostCacheTopMetrics} {
+			for c, m := range metrics {
+				cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+				cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+			}
+		}
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"hosts", "error_count"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("3", "17")
+	mock.ExpectQuery(sanitizeQuery(clientHostCacheQuery)).WillReturnRows(rows)
+
+	columns = []string{"client_address", "error_count", "last_updated"}
+	rows = sqlmock.NewRows(columns).
+		AddRow("10.91.142.80", "12", "8741234567").
+		AddRow("10.91.142.82", "4", "8740000000")
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(clientHostCacheTopQuery, 2))).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
 	go func() {
-		scrapeMySQLConnectionPool(db2, ch2)
-		close(ch2)
+		if err = scrapeClientHostCache(db, ch, 2); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
 	}()
 
-	_ = *readMetric(<-ch2)
+	counterExpected := []metricResult{
+		{"proxysql_client_host_cache_hosts", prometheus.Labels{}, 3, dto.MetricType_GAUGE},
+		{"proxysql_client_host_cache_error_count", prometheus.Labels{}, 17, dto.MetricType_GAUGE},
+		{"proxysql_client_host_cache_top_error_count", prometheus.Labels{"client_address": "10.91.142.80"}, 12, dto.MetricType_GAUGE},
+		{"proxysql_client_host_cache_top_last_updated_seconds", prometheus.Labels{"client_address": "10.91.142.80"}, 8741.234567, dto.MetricType_GAUGE},
+		{"proxysql_client_host_cache_top_error_count", prometheus.Labels{"client_address": "10.91.142.82"}, 4, dto.MetricType_GAUGE},
+		{"proxysql_client_host_cache_top_last_updated_seconds", prometheus.Labels{"client_address": "10.91.142.82"}, 8740, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+		_, ok := <-ch
+		cv.So(ok, convey.ShouldBeFalse)
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeClientHostCacheError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(clientHostCacheQuery)).WillReturnError(errors.New("error"))
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeClientHostCache(db, ch, 20)
+	assert.Error(t, err)
 }
 
 func TestScrapeMySQLConnectionList(t *testing.T) {
@@ -404,6 +1933,14 @@ func TestScrapeDetailedConnectionList(t *testing.T) {
 		AddRow("user_4", "database_4", "10.91.142.89", "1004", 4)
 	mock.ExpectQuery(sanitizeQuery(detailedMySQLProcessListQuery)).WillReturnRows(rows)
 
+	columns = []string{"user", "hostgroup", "command", "time_ms"}
+	rows = sqlmock.NewRows(columns).
+		AddRow("user_1", "1001", "Query", "250").
+		AddRow("user_1", "1001", "Sleep", "900000").
+		AddRow("user_2", "1002", "Query", "1200000").
+		AddRow("user_2", "1001", "Query", "7000")
+	mock.ExpectQuery(sanitizeQuery(mySQLProcessListSessionsQuery)).WillReturnRows(rows)
+
 	ch := make(chan prometheus.Metric)
 	go func() {
 		if err = scrapeDetailedMySQLConnectionList(db, ch); err != nil {
@@ -417,6 +1954,24 @@ func TestScrapeDetailedConnectionList(t *testing.T) {
 		{"proxysql_processlist_detailed_client_connection_count", prometheus.Labels{"client_host": "10.91.142.82", "user": "user_2", "db": "database_2", "hostgroup": "1002"}, 2, dto.MetricType_GAUGE},
 		{"proxysql_processlist_detailed_client_connection_count", prometheus.Labels{"client_host": "10.91.142.88", "user": "user_3", "db": "database_3", "hostgroup": "1003"}, 3, dto.MetricType_GAUGE},
 		{"proxysql_processlist_detailed_client_connection_count", prometheus.Labels{"client_host": "10.91.142.89", "user": "user_4", "db": "database_4", "hostgroup": "1004"}, 4, dto.MetricType_GAUGE},
+		{"proxysql_processlist_command_sessions", prometheus.Labels{"command": "Query"}, 3, dto.MetricType_GAUGE},
+		{"proxysql_processlist_command_sessions", prometheus.Labels{"command": "Sleep"}, 1, dto.MetricType_GAUGE},
+	}
+
+	readHistogram := func(m prometheus.Metric) (prometheus.Labels, uint64, float64, map[float64]uint64) {
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			t.Fatal(err)
+		}
+		labels := make(prometheus.Labels, len(pb.Label))
+		for _, v := range pb.Label {
+			labels[v.GetName()] = v.GetValue()
+		}
+		buckets := make(map[float64]uint64)
+		for _, b := range pb.GetHistogram().GetBucket() {
+			buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+		}
+		return labels, pb.GetHistogram().GetSampleCount(), pb.GetHistogram().GetSampleSum(), buckets
 	}
 
 	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
@@ -424,6 +1979,22 @@ func TestScrapeDetailedConnectionList(t *testing.T) {
 			got := *readMetric(<-ch)
 			cv.So(got, convey.ShouldResemble, expect)
 		}
+
+		labels, count, sum, buckets := readHistogram(<-ch)
+		cv.So(labels, convey.ShouldResemble, prometheus.Labels{"hostgroup": "1001"})
+		cv.So(count, convey.ShouldEqual, 2)
+		cv.So(sum, convey.ShouldEqual, 7.25)
+		cv.So(buckets, convey.ShouldResemble, map[float64]uint64{0.1: 0, 0.5: 1, 1: 1, 5: 1, 10: 2, 30: 2, 60: 2, 300: 2, 600: 2, 1200: 2})
+		labels, count, sum, buckets = readHistogram(<-ch)
+		cv.So(labels, convey.ShouldResemble, prometheus.Labels{"hostgroup": "1002"})
+		cv.So(count, convey.ShouldEqual, 1)
+		cv.So(sum, convey.ShouldEqual, 1200)
+		cv.So(buckets[1200], convey.ShouldEqual, 1)
+
+		cv.So(*readMetric(<-ch), convey.ShouldResemble, metricResult{"proxysql_processlist_longest_query_seconds", prometheus.Labels{"user": "user_1"}, 0.25, dto.MetricType_GAUGE})
+		cv.So(*readMetric(<-ch), convey.ShouldResemble, metricResult{"proxysql_processlist_longest_query_seconds", prometheus.Labels{"user": "user_2"}, 1200, dto.MetricType_GAUGE})
+		_, ok := <-ch
+		cv.So(ok, convey.ShouldBeFalse)
 	})
 
 	// Ensure all SQL queries were executed
@@ -507,6 +2078,91 @@ func TestScrapeDetailedConnectionLisError(t *testing.T) {
 		err = scrapeDetailedMySQLConnectionList(db, ch)
 		assert.Error(t, err)
 	})
+
+	t.Run("error on sessions sql query", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error opening a stub database connection: %s", err)
+		}
+		defer db.Close()
+
+		columns := []string{"user", "db", "cli_host", "hostgroup", "count"}
+		mock.ExpectQuery(sanitizeQuery(detailedMySQLProcessListQuery)).WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectQuery(sanitizeQuery(mySQLProcessListSessionsQuery)).WillReturnError(errors.New("error"))
+
+		ch := make(chan prometheus.Metric)
+
+		err = scrapeDetailedMySQLConnectionList(db, ch)
+		assert.Error(t, err)
+	})
+}
+
+func TestExporterDB(t *testing.T) {
+	// nothing listens on port 1
+	e := NewExporter("stats:stats@tcp(127.0.0.1:1)/", ExporterOptions{})
+	_, err := e.db()
+	assert.Error(t, err)
+	assert.Nil(t, e.dbPool)
+	assert.True(t, e.dbReconnect)
+
+	// pool is reused while health check passes
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	mock.ExpectClose()
+	e.dbPool = db
+	for i := 0; i < 2; i++ {
+		pool, err := e.db()
+		assert.NoError(t, err)
+		assert.Equal(t, db, pool)
+		assert.Equal(t, float64(1), readMetric(e.reconnectsTotal).value)
+	}
+
+	assert.NoError(t, e.Close())
+	assert.Nil(t, e.dbPool)
+	_, err = e.db()
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExporterConcurrentCollect(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	columns := []string{"name", "version", "epoch", "checksum"}
+	mock.ExpectQuery(sanitizeQuery(runtimeChecksumsQuery)).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("mysql_query_rules", "1", "1546004400", "0x8CB14DC08A2E9E60"))
+	mock.ExpectQuery(sanitizeQuery(runtimeChecksumsQuery)).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("mysql_query_rules", "2", "1546004460", "0x6E2AB9C4D1F08A77"))
+
+	e := NewExporter("stats:stats@tcp(127.0.0.1:6032)/", ExporterOptions{ScrapeRuntimeChecksums: true})
+	e.dbPool = db
+
+	// run with -race to check exporter's state is guarded
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ch := make(chan prometheus.Metric)
+			go func() {
+				e.Collect(ch)
+				close(ch)
+			}()
+			for range ch {
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, float64(2), readMetric(e.scrapesTotal).value)
+	assert.Equal(t, float64(1), readMetric(e.checksumChanges.total.WithLabelValues("mysql_query_rules")).value)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestExporter(t *testing.T) {
@@ -515,7 +2171,35 @@ func TestExporter(t *testing.T) {
 	}
 
 	// wait up to 30 seconds for ProxySQL to become available
-	exporter := NewExporter("admin:admin@tcp(127.0.0.1:16032)/", true, true, true, true, true)
+	exporter := NewExporter("admin:admin@tcp(127.0.0.1:16032)/", ExporterOptions{
+		ScrapeMySQLGlobal:              true,
+		ScrapeMySQLConnectionPool:      true,
+		ScrapeMySQLConnectionList:      true,
+		ScrapeDetailedMySQLProcessList: true,
+		ScrapeMemoryMetrics:            true,
+		ScrapeMySQLQueryDigest:         true,
+		ScrapeMySQLCommandsCounters:    true,
+		ScrapeMySQLErrors:              true,
+		ScrapeMonitor:                  true,
+		ScrapeRuntimeMySQLServers:      true,
+		ScrapeHostgroups:               true,
+		ScrapeProxySQLServers:          true,
+		ScrapeMySQLUsers:               true,
+		ScrapeMySQLQueryRules:          true,
+		ScrapeMySQLFreeConnections:     true,
+		ScrapePreparedStatements:       true,
+		ScrapeGTIDExecuted:             true,
+		ScrapeStatsHistory:             true,
+		ScrapeGlobalVariables:          true,
+		ScrapeRuntimeChecksums:         true,
+		ScrapeClientHostCache:          true,
+
+		QueryDigestLimit:        50,
+		QueryDigestOrderBy:      queryDigestOrderBySumTime,
+		PreparedStatementsLimit: 20,
+		GlobalVariablesExclude:  regexp.MustCompile("password|credentials"),
+		ClientHostCacheLimit:    20,
+	})
 	for i := 0; i < 30; i++ {
 		db, err := exporter.db()
 		if err != nil {
@@ -590,5 +2274,7 @@ SAVE MYSQL USERS TO DISK;
 			convey.ShouldBeIn, metrics)
 		cv.So(metricResult{"proxysql_connection_pool_latency_us", prometheus.Labels{"hostgroup": "1", "endpoint": "percona-server:3306"}, 0, dto.MetricType_GAUGE},
 			convey.ShouldBeIn, metrics)
+		cv.So(metricResult{"proxysql_runtime_mysql_servers_max_connections", prometheus.Labels{"hostgroup": "1", "endpoint": "mysql:3306"}, 0, dto.MetricType_GAUGE},
+			convey.ShouldBeIn, metrics)
 	})
 }
@@ -0,0 +1,126 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gtidInterval is a closed interval of transaction numbers.
+type gtidInterval struct {
+	start, end int64
+}
+
+// gtidSet is a parsed MySQL GTID set: source UUID -> sorted non-overlapping intervals.
+type gtidSet map[string][]gtidInterval
+
+// parseGTIDSet parses GTID set in MySQL format, for example
+// "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5:11,4B2C2D6E-71CA-11E1-9E33-C80AA9429562:1-3".
+func parseGTIDSet(s string) (gtidSet, error) {
+	set := make(gtidSet)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		fields := strings.Split(part, ":")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid GTID set %q", part)
+		}
+		uuid := strings.ToLower(fields[0])
+		for _, field := range fields[1:] {
+			var interval gtidInterval
+			var err error
+			bounds := strings.SplitN(field, "-", 2)
+			if interval.start, err = strconv.ParseInt(bounds[0], 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid GTID set %q: %s", part, err)
+			}
+			interval.end = interval.start
+			if len(bounds) == 2 {
+				if interval.end, err = strconv.ParseInt(bounds[1], 10, 64); err != nil {
+					return nil, fmt.Errorf("invalid GTID set %q: %s", part, err)
+				}
+			}
+			if interval.end < interval.start {
+				return nil, fmt.Errorf("invalid GTID set %q: interval %s", part, field)
+			}
+			set[uuid] = append(set[uuid], interval)
+		}
+	}
+
+	for uuid, intervals := range set {
+		set[uuid] = mergeGTIDIntervals(intervals)
+	}
+	return set, nil
+}
+
+// mergeGTIDIntervals sorts intervals and merges overlapping and adjacent ones.
+func mergeGTIDIntervals(intervals []gtidInterval) []gtidInterval {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].start < intervals[j].start })
+	merged := intervals[:1]
+	for _, interval := range intervals[1:] {
+		last := &merged[len(merged)-1]
+		if interval.start <= last.end+1 {
+			if interval.end > last.end {
+				last.end = interval.end
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
+
+// union returns a new set with all transactions from both sets.
+func (s gtidSet) union(other gtidSet) gtidSet {
+	res := make(gtidSet, len(s))
+	for _, set := range []gtidSet{s, other} {
+		for uuid, intervals := range set {
+			res[uuid] = append(res[uuid], intervals...)
+		}
+	}
+	for uuid, intervals := range res {
+		res[uuid] = mergeGTIDIntervals(append([]gtidInterval(nil), intervals...))
+	}
+	return res
+}
+
+// missing returns the number of transactions in s which are not in other.
+func (s gtidSet) missing(other gtidSet) int64 {
+	var res int64
+	for uuid, intervals := range s {
+		others := other[uuid]
+		for _, a := range intervals {
+			res += a.end - a.start + 1
+			for _, b := range others {
+				start, end := a.start, a.end
+				if b.start > start {
+					start = b.start
+				}
+				if b.end < end {
+					end = b.end
+				}
+				if start <= end {
+					res -= end - start + 1
+				}
+			}
+		}
+	}
+	return res
+}
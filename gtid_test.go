@@ -0,0 +1,75 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	uuid1 = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	uuid2 = "4b2c2d6e-71ca-11e1-9e33-c80aa9429562"
+)
+
+func TestParseGTIDSet(t *testing.T) {
+	set, err := parseGTIDSet("3E11FA47-71CA-11E1-9E33-C80AA9429562:11:1-5:6-8,\n4B2C2D6E-71CA-11E1-9E33-C80AA9429562:1-3")
+	require.NoError(t, err)
+	assert.Equal(t, gtidSet{
+		uuid1: {{1, 8}, {11, 11}},
+		uuid2: {{1, 3}},
+	}, set)
+
+	set, err = parseGTIDSet("")
+	require.NoError(t, err)
+	assert.Empty(t, set)
+
+	for _, s := range []string{uuid1, uuid1 + ":a-5", uuid1 + ":1-b", uuid1 + ":5-1"} {
+		_, err = parseGTIDSet(s)
+		assert.Error(t, err, "%q", s)
+	}
+}
+
+func TestGTIDSetMissing(t *testing.T) {
+	writer, err := parseGTIDSet(uuid1 + ":1-100," + uuid2 + ":1-10")
+	require.NoError(t, err)
+
+	for s, expected := range map[string]int64{
+		uuid1 + ":1-100," + uuid2 + ":1-10":      0,
+		uuid1 + ":1-90," + uuid2 + ":1-10":       10,
+		uuid1 + ":1-50:61-100," + uuid2 + ":1-5": 15,
+		uuid1 + ":1-200":                         10,
+		"":                                       110,
+	} {
+		reader, err := parseGTIDSet(s)
+		require.NoError(t, err)
+		assert.Equal(t, expected, writer.missing(reader), "%q", s)
+	}
+}
+
+func TestGTIDSetUnion(t *testing.T) {
+	a, err := parseGTIDSet(uuid1 + ":1-10")
+	require.NoError(t, err)
+	b, err := parseGTIDSet(uuid1 + ":11-20:30," + uuid2 + ":1")
+	require.NoError(t, err)
+
+	assert.Equal(t, gtidSet{
+		uuid1: {{1, 20}, {30, 30}},
+		uuid2: {{1, 1}},
+	}, a.union(b))
+	assert.Equal(t, gtidSet{uuid1: {{1, 10}}}, a, "union should not modify the receiver")
+}
@@ -0,0 +1,189 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+// instanceLabel is added to all metrics of an instance in multi-instance mode.
+const instanceLabel = "instance"
+
+// metricLabels are label names of exporter's metrics, they can't be used as extra labels of instances.
+var metricLabels = map[string]struct{}{
+	"active":                {},
+	"charset":               {},
+	"checksum":              {},
+	"client_address":        {},
+	"client_host":           {},
+	"cluster_type":          {},
+	"collector":             {},
+	"command":               {},
+	"comment":               {},
+	"db":                    {},
+	"destination_hostgroup": {},
+	"digest":                {},
+	"endpoint":              {},
+	"errno":                 {},
+	"hostgroup":             {},
+	"le":                    {},
+	"log":                   {},
+	"module":                {},
+	"name":                  {},
+	"peer":                  {},
+	"quantile":              {},
+	"role":                  {},
+	"rule_id":               {},
+	"schemaname":            {},
+	"side":                  {},
+	"status":                {},
+	"user":                  {},
+	"username":              {},
+	"value":                 {},
+	"writer_hostgroup":      {},
+}
+
+// instanceConfig describes a single ProxySQL instance.
+type instanceConfig struct {
+	Name   string            `yaml:"name"`
+	DSN    string            `yaml:"dsn"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+// instancesConfig is a content of -config.instances file.
+type instancesConfig struct {
+	Instances []instanceConfig `yaml:"instances"`
+}
+
+// loadInstancesConfig reads, parses and checks instances configuration file.
+func loadInstancesConfig(filename string) (*instancesConfig, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var cfg instancesConfig
+	if err = yaml.UnmarshalStrict(b, &cfg); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Instances) == 0 {
+		return nil, fmt.Errorf("no instances in %s", filename)
+	}
+	names := make(map[string]struct{}, len(cfg.Instances))
+	for _, inst := range cfg.Instances {
+		if inst.Name == "" || inst.DSN == "" {
+			return nil, fmt.Errorf("instance should have both name and dsn")
+		}
+		if _, ok := names[inst.Name]; ok {
+			return nil, fmt.Errorf("duplicate instance name %q", inst.Name)
+		}
+		names[inst.Name] = struct{}{}
+		for label := range inst.Labels {
+			if !model.LabelName(label).IsValid() || strings.HasPrefix(label, model.ReservedLabelPrefix) {
+				return nil, fmt.Errorf("instance %q: invalid label name %q", inst.Name, label)
+			}
+			if _, ok := metricLabels[label]; ok || label == instanceLabel {
+				return nil, fmt.Errorf("instance %q: label %q is reserved", inst.Name, label)
+			}
+		}
+	}
+
+	// all instances should have the same label names to be merged into the same metric families
+	labels := make(map[string]struct{})
+	for _, inst := range cfg.Instances {
+		for label := range inst.Labels {
+			labels[label] = struct{}{}
+		}
+	}
+	for i, inst := range cfg.Instances {
+		if len(inst.Labels) == len(labels) {
+			continue
+		}
+		if inst.Labels == nil {
+			cfg.Instances[i].Labels = make(map[string]string, len(labels))
+		}
+		for label := range labels {
+			if _, ok := inst.Labels[label]; !ok {
+				cfg.Instances[i].Labels[label] = ""
+			}
+		}
+	}
+	return &cfg, nil
+}
+
+// instanceGatherer adds instance name and extra labels to all metrics of wrapped gatherer.
+type instanceGatherer struct {
+	gatherer prometheus.Gatherer
+	labels   []*dto.LabelPair
+}
+
+// newInstanceGatherer returns a new gatherer for given instance.
+func newInstanceGatherer(inst instanceConfig, gatherer prometheus.Gatherer) *instanceGatherer {
+	labels := []*dto.LabelPair{{Name: proto.String(instanceLabel), Value: proto.String(inst.Name)}}
+	for name, value := range inst.Labels {
+		labels = append(labels, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+	}
+	return &instanceGatherer{
+		gatherer: gatherer,
+		labels:   labels,
+	}
+}
+
+// check interface
+var _ prometheus.Gatherer = (*instanceGatherer)(nil)
+
+// Gather implements prometheus.Gatherer.
+func (g *instanceGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.gatherer.Gather()
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			m.Label = append(m.Label, g.labels...)
+			sort.Sort(prometheus.LabelPairSorter(m.Label))
+		}
+	}
+	return mfs, err
+}
+
+// instancesGatherer gathers all instances concurrently and merges the result.
+type instancesGatherer []*instanceGatherer
+
+// check interface
+var _ prometheus.Gatherer = (instancesGatherer)(nil)
+
+// Gather implements prometheus.Gatherer.
+func (gs instancesGatherer) Gather() ([]*dto.MetricFamily, error) {
+	merged := make(prometheus.Gatherers, len(gs))
+	var wg sync.WaitGroup
+	for i, g := range gs {
+		wg.Add(1)
+		go func(i int, g *instanceGatherer) {
+			defer wg.Done()
+			mfs, err := g.Gather()
+			merged[i] = prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return mfs, err })
+		}(i, g)
+	}
+	wg.Wait()
+	return merged.Gather()
+}
@@ -0,0 +1,258 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// usedLabels returns label names used by metrics in exporter.go: literals passed as variable labels
+// to prometheus.NewDesc and prometheus.New*Vec (directly or via assigned variables), and keys of constant labels.
+func usedLabels(t *testing.T) map[string]struct{} {
+	f, err := parser.ParseFile(token.NewFileSet(), "exporter.go", nil, 0)
+	require.NoError(t, err)
+
+	// values assigned to variables by name, for labels passed as variables
+	assigned := make(map[string][]ast.Expr)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if a, ok := n.(*ast.AssignStmt); ok && len(a.Lhs) == len(a.Rhs) {
+			for i, l := range a.Lhs {
+				if ident, ok := l.(*ast.Ident); ok {
+					assigned[ident.Name] = append(assigned[ident.Name], a.Rhs[i])
+				}
+			}
+		}
+		return true
+	})
+
+	res := make(map[string]struct{})
+	var addAll func(e ast.Expr)
+	addAll = func(e ast.Expr) {
+		if ident, ok := e.(*ast.Ident); ok {
+			for _, v := range assigned[ident.Name] {
+				addAll(v)
+			}
+			return
+		}
+		ast.Inspect(e, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				s, err := strconv.Unquote(lit.Value)
+				require.NoError(t, err)
+				res[s] = struct{}{}
+			}
+			return true
+		})
+	}
+	isLabels := func(e ast.Expr) bool {
+		sel, ok := e.(*ast.SelectorExpr)
+		return ok && sel.Sel.Name == "Labels"
+	}
+	addKeys := func(lit *ast.CompositeLit) {
+		for _, e := range lit.Elts {
+			addAll(e.(*ast.KeyValueExpr).Key)
+		}
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			sel, ok := n.Fun.(*ast.SelectorExpr)
+			if !ok {
+				break
+			}
+			if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "prometheus" {
+				break
+			}
+			switch {
+			case sel.Sel.Name == "NewDesc" && len(n.Args) == 4:
+				addAll(n.Args[2])
+			case strings.HasSuffix(sel.Sel.Name, "Vec") && len(n.Args) == 2:
+				addAll(n.Args[1])
+			}
+		case *ast.CompositeLit:
+			switch typ := n.Type.(type) {
+			case *ast.MapType:
+				// map[string]prometheus.Labels{"name": {"label": "value"}}
+				if isLabels(typ.Value) {
+					for _, e := range n.Elts {
+						if lit, ok := e.(*ast.KeyValueExpr).Value.(*ast.CompositeLit); ok {
+							addKeys(lit)
+						}
+					}
+				}
+			default:
+				if isLabels(typ) {
+					addKeys(n)
+				}
+			}
+		}
+		return true
+	})
+	return res
+}
+
+func TestMetricLabels(t *testing.T) {
+	used := usedLabels(t)
+	for _, l := range []string{"collector", "side", "comment", "checksum"} {
+		require.Contains(t, used, l, "exporter.go is not parsed correctly")
+	}
+	for l := range used {
+		assert.Contains(t, metricLabels, l)
+	}
+}
+
+func TestLoadInstancesConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "proxysql_exporter")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	defer os.Remove(f.Name())
+
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte(`
+instances:
+  - name: proxysql-0
+    dsn: stats:stats@tcp(10.91.142.80:6032)/
+    labels:
+      cluster: main
+      az: us-east-1a
+  - name: proxysql-1
+    dsn: stats:stats@tcp(10.91.142.82:6032)/
+`), 0600))
+	cfg, err := loadInstancesConfig(f.Name())
+	require.NoError(t, err)
+	expected := &instancesConfig{
+		Instances: []instanceConfig{
+			{Name: "proxysql-0", DSN: "stats:stats@tcp(10.91.142.80:6032)/", Labels: map[string]string{"cluster": "main", "az": "us-east-1a"}},
+			{Name: "proxysql-1", DSN: "stats:stats@tcp(10.91.142.82:6032)/", Labels: map[string]string{"cluster": "", "az": ""}},
+		},
+	}
+	assert.Equal(t, expected, cfg)
+
+	for _, content := range []string{
+		"instances: []\n",
+		"instances:\n  - name: proxysql-0\n",
+		"instances:\n  - {name: proxysql-0, dsn: 'stats:stats@tcp(a:6032)/'}\n  - {name: proxysql-0, dsn: 'stats:stats@tcp(b:6032)/'}\n",
+		"instances:\n  - {name: proxysql-0, dsn: 'stats:stats@tcp(a:6032)/', labels: {instance: a}}\n",
+		"instances:\n  - {name: proxysql-0, dsn: 'stats:stats@tcp(a:6032)/', labels: {hostgroup: a}}\n",
+		"instances:\n  - {name: proxysql-0, dsn: 'stats:stats@tcp(a:6032)/', labels: {endpoint: a}}\n",
+		"instances:\n  - {name: proxysql-0, dsn: 'stats:stats@tcp(a:6032)/', labels: {__name__: a}}\n",
+		"instances:\n  - {name: proxysql-0, dsn: 'stats:stats@tcp(a:6032)/', labels: {availability-zone: a}}\n",
+	} {
+		require.NoError(t, ioutil.WriteFile(f.Name(), []byte(content), 0600))
+		_, err = loadInstancesConfig(f.Name())
+		assert.Error(t, err, "%s", content)
+	}
+}
+
+func TestInstancesGatherer(t *testing.T) {
+	var gatherers instancesGatherer
+	for i, inst := range []instanceConfig{
+		{Name: "proxysql-0", Labels: map[string]string{"cluster": "main"}},
+		{Name: "proxysql-1", Labels: map[string]string{"cluster": "main"}},
+	} {
+		up := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "up",
+			Help:      "Whether ProxySQL is up.",
+		})
+		up.Set(float64(i))
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(up)
+		gatherers = append(gatherers, newInstanceGatherer(inst, registry))
+	}
+
+	mfs, err := gatherers.Gather()
+	require.NoError(t, err)
+	require.Len(t, mfs, 1)
+	assert.Equal(t, "proxysql_up", mfs[0].GetName())
+	require.Len(t, mfs[0].Metric, 2)
+	for i, m := range mfs[0].Metric {
+		labels := make(map[string]string)
+		var names []string
+		for _, l := range m.Label {
+			labels[l.GetName()] = l.GetValue()
+			names = append(names, l.GetName())
+		}
+		assert.Equal(t, []string{"cluster", "instance"}, names)
+		assert.Equal(t, "main", labels["cluster"])
+		assert.Equal(t, float64(i), m.GetGauge().GetValue())
+	}
+	assert.Equal(t, "proxysql-0", mfs[0].Metric[0].Label[1].GetValue())
+	assert.Equal(t, "proxysql-1", mfs[0].Metric[1].Label[1].GetValue())
+}
+
+func TestInstancesGathererMismatchedLabels(t *testing.T) {
+	f, err := ioutil.TempFile("", "proxysql_exporter")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	defer os.Remove(f.Name())
+
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte(`
+instances:
+  - name: proxysql-0
+    dsn: stats:stats@tcp(10.91.142.80:6032)/
+    labels:
+      cluster: main
+  - name: proxysql-1
+    dsn: stats:stats@tcp(10.91.142.82:6032)/
+    labels:
+      az: us-east-1a
+  - name: proxysql-2
+    dsn: stats:stats@tcp(10.91.142.84:6032)/
+`), 0600))
+	cfg, err := loadInstancesConfig(f.Name())
+	require.NoError(t, err)
+
+	var gatherers instancesGatherer
+	for _, inst := range cfg.Instances {
+		up := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "up",
+			Help:      "Whether ProxySQL is up.",
+		})
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(up)
+		gatherers = append(gatherers, newInstanceGatherer(inst, registry))
+	}
+
+	mfs, err := gatherers.Gather()
+	require.NoError(t, err)
+	require.Len(t, mfs, 1)
+	require.Len(t, mfs[0].Metric, 3)
+	var actual []map[string]string
+	for _, m := range mfs[0].Metric {
+		labels := make(map[string]string)
+		for _, l := range m.Label {
+			labels[l.GetName()] = l.GetValue()
+		}
+		actual = append(actual, labels)
+	}
+	expected := []map[string]string{
+		{"az": "", "cluster": "main", "instance": "proxysql-0"},
+		{"az": "us-east-1a", "cluster": "", "instance": "proxysql-1"},
+		{"az": "", "cluster": "", "instance": "proxysql-2"},
+	}
+	assert.ElementsMatch(t, expected, actual)
+}
@@ -0,0 +1,406 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/prometheus/common/log"
+	"gopkg.in/yaml.v2"
+)
+
+// Pod annotations which configure discovered ProxySQL instances.
+const (
+	// Admin interface port, 6032 by default.
+	kubernetesAdminPortAnnotation = "proxysql-exporter.percona.com/admin-port"
+
+	// Name of a secret in the pod's namespace with username and password keys,
+	// credentials of DATA_SOURCE_NAME are used by default.
+	kubernetesCredentialsSecretAnnotation = "proxysql-exporter.percona.com/credentials-secret"
+
+	kubernetesDefaultAdminPort = "6032"
+)
+
+// Paths inside a pod where Kubernetes mounts service account credentials.
+const (
+	kubernetesServiceAccountToken     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	kubernetesServiceAccountCA        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	kubernetesServiceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// kubernetesPod contains pod fields used by discovery.
+type kubernetesPod struct {
+	Metadata struct {
+		Name            string            `json:"name"`
+		Namespace       string            `json:"namespace"`
+		ResourceVersion string            `json:"resourceVersion"`
+		Annotations     map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Status struct {
+		Phase string `json:"phase"`
+		PodIP string `json:"podIP"`
+	} `json:"status"`
+}
+
+// kubernetesClient is a subset of Kubernetes API used by discovery.
+type kubernetesClient interface {
+	// listPods returns pods in given namespace matching given label selector.
+	listPods(namespace, selector string) ([]kubernetesPod, error)
+
+	// getSecret returns decoded data of given secret.
+	getSecret(namespace, name string) (map[string][]byte, error)
+}
+
+// httpKubernetesClient implements kubernetesClient with Kubernetes REST API.
+type httpKubernetesClient struct {
+	server string
+	token  string
+	client *http.Client
+}
+
+// check interface
+var _ kubernetesClient = (*httpKubernetesClient)(nil)
+
+// get decodes JSON response for given API path and query into res.
+func (c *httpKubernetesClient) get(path string, query url.Values, res interface{}) error {
+	u := strings.TrimSuffix(c.server, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("GET %s: %s: %s", u, resp.Status, b)
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+func (c *httpKubernetesClient) listPods(namespace, selector string) ([]kubernetesPod, error) {
+	var res struct {
+		Items []kubernetesPod `json:"items"`
+	}
+	query := url.Values{}
+	if selector != "" {
+		query.Set("labelSelector", selector)
+	}
+	path := "/api/v1/pods"
+	if namespace != "" {
+		path = "/api/v1/namespaces/" + url.PathEscape(namespace) + "/pods"
+	}
+	if err := c.get(path, query, &res); err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
+
+func (c *httpKubernetesClient) getSecret(namespace, name string) (map[string][]byte, error) {
+	// values are base64-encoded, and encoding/json decodes them into []byte
+	var res struct {
+		Data map[string][]byte `json:"data"`
+	}
+	path := "/api/v1/namespaces/" + url.PathEscape(namespace) + "/secrets/" + url.PathEscape(name)
+	if err := c.get(path, nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// newInClusterKubernetesClient returns a client configured with pod's service account,
+// and pod's namespace.
+func newInClusterKubernetesClient() (*httpKubernetesClient, string, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, "", fmt.Errorf("KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set, not running in a cluster?")
+	}
+	token, err := ioutil.ReadFile(kubernetesServiceAccountToken)
+	if err != nil {
+		return nil, "", err
+	}
+	ca, err := ioutil.ReadFile(kubernetesServiceAccountCA)
+	if err != nil {
+		return nil, "", err
+	}
+	namespace, err := ioutil.ReadFile(kubernetesServiceAccountNamespace)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tlsConfig, err := kubernetesTLSConfig(ca, nil, nil, false)
+	if err != nil {
+		return nil, "", err
+	}
+	c := &httpKubernetesClient{
+		server: "https://" + net.JoinHostPort(host, port),
+		token:  strings.TrimSpace(string(token)),
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+			Timeout:   30 * time.Second,
+		},
+	}
+	return c, strings.TrimSpace(string(namespace)), nil
+}
+
+// kubeconfig contains kubeconfig file fields used by discovery.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// readKubeconfigData returns base64-decoded inline data, or content of the file.
+func readKubeconfigData(data, filename string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if filename != "" {
+		return ioutil.ReadFile(filename)
+	}
+	return nil, nil
+}
+
+// newKubeconfigKubernetesClient returns a client configured with current context of given kubeconfig file,
+// and context's namespace. Only token and client certificate authentication is supported.
+func newKubeconfigKubernetesClient(filename string) (*httpKubernetesClient, string, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, "", err
+	}
+	var cfg kubeconfig
+	if err = yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, "", err
+	}
+
+	c := &httpKubernetesClient{}
+	var namespace string
+	var ca, cert, key []byte
+	var insecure, found bool
+	for _, ctx := range cfg.Contexts {
+		if ctx.Name != cfg.CurrentContext {
+			continue
+		}
+		found = true
+		namespace = ctx.Context.Namespace
+		for _, cl := range cfg.Clusters {
+			if cl.Name != ctx.Context.Cluster {
+				continue
+			}
+			c.server = cl.Cluster.Server
+			insecure = cl.Cluster.InsecureSkipTLSVerify
+			if ca, err = readKubeconfigData(cl.Cluster.CertificateAuthorityData, cl.Cluster.CertificateAuthority); err != nil {
+				return nil, "", err
+			}
+		}
+		for _, u := range cfg.Users {
+			if u.Name != ctx.Context.User {
+				continue
+			}
+			c.token = u.User.Token
+			if cert, err = readKubeconfigData(u.User.ClientCertificateData, u.User.ClientCertificate); err != nil {
+				return nil, "", err
+			}
+			if key, err = readKubeconfigData(u.User.ClientKeyData, u.User.ClientKey); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if !found {
+		return nil, "", fmt.Errorf("%s: current context %q not found", filename, cfg.CurrentContext)
+	}
+	if c.server == "" {
+		return nil, "", fmt.Errorf("%s: no server for current context %q", filename, cfg.CurrentContext)
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	tlsConfig, err := kubernetesTLSConfig(ca, cert, key, insecure)
+	if err != nil {
+		return nil, "", err
+	}
+	c.client = &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		Timeout:   30 * time.Second,
+	}
+	return c, namespace, nil
+}
+
+// kubernetesTLSConfig returns TLS configuration with given PEM-encoded CA certificates and client certificate.
+func kubernetesTLSConfig(ca, cert, key []byte, insecure bool) (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: insecure,
+	}
+	if len(ca) > 0 {
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("failed to parse Kubernetes CA certificates")
+		}
+	}
+	if len(cert) > 0 || len(key) > 0 {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
+
+// kubernetesDiscovery exports ProxySQL instances running in Kubernetes pods matching label selector.
+type kubernetesDiscovery struct {
+	*discoveredInstances
+	client    kubernetesClient
+	namespace string
+	selector  string
+	baseDSN   string
+
+	dsns map[string]kubernetesPodDSN // key - namespace/name; accessed only by refresh
+}
+
+// kubernetesPodDSN is a DSN of pod with credentials from secret, cached until the pod changes.
+type kubernetesPodDSN struct {
+	resourceVersion string
+	dsn             string
+}
+
+// newKubernetesDiscovery returns a new discovery of pods in given namespace (all namespaces if empty)
+// matching given label selector. Credentials and parameters of base DSN are used for all instances
+// unless overridden by pod annotations.
+func newKubernetesDiscovery(client kubernetesClient, namespace, selector, baseDSN string, newExporter func(dsn string) *Exporter) *kubernetesDiscovery {
+	return &kubernetesDiscovery{
+		discoveredInstances: newDiscoveredInstances(newExporter),
+		client:              client,
+		namespace:           namespace,
+		selector:            selector,
+		baseDSN:             baseDSN,
+		dsns:                make(map[string]kubernetesPodDSN),
+	}
+}
+
+// refresh discovers running pods. Pods with invalid configuration are skipped.
+func (d *kubernetesDiscovery) refresh() error {
+	pods, err := d.client.listPods(d.namespace, d.selector)
+	if err != nil {
+		return err
+	}
+
+	// credentials secrets are read only for new or changed pods
+	var instances []instanceConfig
+	dsns := make(map[string]kubernetesPodDSN, len(pods))
+	for _, pod := range pods {
+		if pod.Status.Phase != "Running" || pod.Status.PodIP == "" {
+			continue
+		}
+		name := pod.Metadata.Namespace + "/" + pod.Metadata.Name
+		dsn, ok := d.dsns[name]
+		if !ok || dsn.resourceVersion == "" || dsn.resourceVersion != pod.Metadata.ResourceVersion {
+			s, err := d.podDSN(pod)
+			if err != nil {
+				log.Errorf("Skipping pod %s: %s", name, err)
+				continue
+			}
+			dsn = kubernetesPodDSN{resourceVersion: pod.Metadata.ResourceVersion, dsn: s}
+		}
+		dsns[name] = dsn
+		instances = append(instances, instanceConfig{
+			Name: name,
+			DSN:  dsn.dsn,
+		})
+	}
+	d.dsns = dsns
+	d.update(instances)
+	return nil
+}
+
+// podDSN returns DSN for ProxySQL admin interface of given pod, with default dial timeout if base DSN has none.
+func (d *kubernetesDiscovery) podDSN(pod kubernetesPod) (string, error) {
+	cfg, err := mysql.ParseDSN(d.baseDSN)
+	if err != nil {
+		return "", err
+	}
+
+	port := pod.Metadata.Annotations[kubernetesAdminPortAnnotation]
+	if port == "" {
+		port = kubernetesDefaultAdminPort
+	}
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(pod.Status.PodIP, port)
+	if cfg.Timeout == 0 {
+		cfg.Timeout = discoveredInstanceTimeout
+	}
+
+	if secret := pod.Metadata.Annotations[kubernetesCredentialsSecretAnnotation]; secret != "" {
+		data, err := d.client.getSecret(pod.Metadata.Namespace, secret)
+		if err != nil {
+			return "", err
+		}
+		if len(data["username"]) == 0 {
+			return "", fmt.Errorf("secret %s has no username", secret)
+		}
+		cfg.User = string(data["username"])
+		cfg.Passwd = string(data["password"])
+	}
+	return cfg.FormatDSN(), nil
+}
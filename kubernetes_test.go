@@ -0,0 +1,188 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKubernetesClient struct {
+	pods    []kubernetesPod
+	secrets map[string]map[string][]byte // key - namespace/name
+	gets    int
+}
+
+func (c *fakeKubernetesClient) listPods(namespace, selector string) ([]kubernetesPod, error) {
+	return c.pods, nil
+}
+
+func (c *fakeKubernetesClient) getSecret(namespace, name string) (map[string][]byte, error) {
+	c.gets++
+	data, ok := c.secrets[namespace+"/"+name]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s not found", namespace, name)
+	}
+	return data, nil
+}
+
+func fakePod(name, phase, ip string, annotations map[string]string) kubernetesPod {
+	var pod kubernetesPod
+	pod.Metadata.Name = name
+	pod.Metadata.Namespace = "db"
+	pod.Metadata.ResourceVersion = "1"
+	pod.Metadata.Annotations = annotations
+	pod.Status.Phase = phase
+	pod.Status.PodIP = ip
+	return pod
+}
+
+func TestKubernetesDiscovery(t *testing.T) {
+	client := &fakeKubernetesClient{
+		pods: []kubernetesPod{
+			fakePod("proxysql-0", "Running", "127.0.0.1", map[string]string{kubernetesAdminPortAnnotation: "1"}),
+			fakePod("proxysql-1", "Running", "127.0.0.2", map[string]string{
+				kubernetesAdminPortAnnotation:         "2",
+				kubernetesCredentialsSecretAnnotation: "proxysql-admin",
+			}),
+			fakePod("proxysql-2", "Pending", "", nil),
+			fakePod("proxysql-3", "Running", "127.0.0.3", map[string]string{kubernetesCredentialsSecretAnnotation: "missing"}),
+		},
+		secrets: map[string]map[string][]byte{
+			"db/proxysql-admin": {"username": []byte("admin"), "password": []byte("secret")},
+		},
+	}
+
+	var dsns []string
+	d := newKubernetesDiscovery(client, "db", "app=proxysql", "stats:stats@tcp(localhost:6032)/?timeout=1s", func(dsn string) *Exporter {
+		dsns = append(dsns, dsn)
+		return NewExporter(dsn, ExporterOptions{})
+	})
+
+	// nothing listens on ports 1 and 2, so pods are down
+	require.NoError(t, d.refresh())
+	assert.Equal(t, []string{"127.0.0.1:1", "127.0.0.2:2"}, d.addresses())
+	assert.ElementsMatch(t, []string{
+		"stats:stats@tcp(127.0.0.1:1)/?timeout=1s",
+		"admin:secret@tcp(127.0.0.2:2)/?timeout=1s",
+	}, dsns)
+
+	assert.Equal(t, 2, client.gets)
+
+	// secrets of unchanged pods are not read again
+	require.NoError(t, d.refresh())
+	assert.Equal(t, 3, client.gets) // pod with missing secret
+	assert.Len(t, dsns, 2)
+
+	// changed pod reads secret again
+	client.pods = client.pods[1:2]
+	client.pods[0].Metadata.ResourceVersion = "2"
+	client.secrets["db/proxysql-admin"]["password"] = []byte("changed")
+	require.NoError(t, d.refresh())
+	assert.Equal(t, []string{"127.0.0.2:2"}, d.addresses())
+	assert.Equal(t, 4, client.gets)
+	assert.Equal(t, "admin:changed@tcp(127.0.0.2:2)/?timeout=1s", dsns[len(dsns)-1])
+}
+
+func TestHTTPKubernetesClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/namespaces/db/pods":
+			assert.Equal(t, "app=proxysql", r.URL.Query().Get("labelSelector"))
+			fmt.Fprint(w, `{"items": [{
+				"metadata": {"name": "proxysql-0", "namespace": "db", "resourceVersion": "1", "annotations": {"proxysql-exporter.percona.com/admin-port": "6032"}},
+				"status": {"phase": "Running", "podIP": "10.91.142.80"}
+			}]}`)
+		case "/api/v1/namespaces/db/secrets/proxysql-admin":
+			fmt.Fprint(w, `{"data": {"username": "YWRtaW4=", "password": "c2VjcmV0"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	c := &httpKubernetesClient{server: ts.URL, token: "token", client: ts.Client()}
+
+	pods, err := c.listPods("db", "app=proxysql")
+	require.NoError(t, err)
+	expected := fakePod("proxysql-0", "Running", "10.91.142.80", map[string]string{kubernetesAdminPortAnnotation: "6032"})
+	assert.Equal(t, []kubernetesPod{expected}, pods)
+
+	data, err := c.getSecret("db", "proxysql-admin")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"username": []byte("admin"), "password": []byte("secret")}, data)
+
+	_, err = c.getSecret("db", "missing")
+	assert.Error(t, err)
+
+	c.token = "wrong"
+	_, err = c.listPods("db", "app=proxysql")
+	assert.Error(t, err)
+}
+
+func TestNewKubeconfigKubernetesClient(t *testing.T) {
+	f, err := ioutil.TempFile("", "proxysql_exporter")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	defer os.Remove(f.Name())
+
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte(`
+apiVersion: v1
+kind: Config
+current-context: prod
+clusters:
+  - name: dev
+    cluster:
+      server: https://dev.example.com
+  - name: prod
+    cluster:
+      server: https://prod.example.com
+      insecure-skip-tls-verify: true
+users:
+  - name: admin
+    user:
+      token: token
+contexts:
+  - name: dev
+    context:
+      cluster: dev
+      user: admin
+  - name: prod
+    context:
+      cluster: prod
+      user: admin
+      namespace: db
+`), 0600))
+	c, namespace, err := newKubeconfigKubernetesClient(f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, "https://prod.example.com", c.server)
+	assert.Equal(t, "token", c.token)
+	assert.Equal(t, "db", namespace)
+
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte("current-context: missing\n"), 0600))
+	_, _, err = newKubeconfigKubernetesClient(f.Name())
+	assert.Error(t, err)
+}
@@ -0,0 +1,231 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/log"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultAuthModule is used when probe request has no auth_module parameter.
+const defaultAuthModule = "default"
+
+// authModule contains credentials for ProxySQL admin interface.
+type authModule struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+// probeConfig is a content of -probe.config file.
+type probeConfig struct {
+	AuthModules map[string]authModule `yaml:"auth_modules"`
+}
+
+// loadProbeConfig reads and parses probe configuration file.
+func loadProbeConfig(filename string) (*probeConfig, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var cfg probeConfig
+	if err = yaml.UnmarshalStrict(b, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.AuthModules) == 0 {
+		return nil, fmt.Errorf("no auth_modules in %s", filename)
+	}
+	return &cfg, nil
+}
+
+// dsn returns DSN for given target address.
+func (m authModule) dsn(target string) string {
+	cfg := mysql.NewConfig()
+	cfg.User = m.User
+	cfg.Passwd = m.Password
+	cfg.Net = "tcp"
+	cfg.Addr = target
+	return cfg.FormatDSN()
+}
+
+// probeExporter is an Exporter for a single target with own registry.
+// Exporters are kept between probes to preserve counters computed by the exporter itself.
+type probeExporter struct {
+	m        sync.Mutex
+	exporter *Exporter
+	registry *prometheus.Registry
+	lastUsed time.Time // protected by probeHandler.m
+	evicted  bool      // protected by m
+}
+
+// probeHandler serves metrics of ProxySQL instance given by target parameter, in the style of blackbox_exporter.
+// At most maxExporters exporters are kept (0 for unlimited); least recently used ones and ones unused for ttl
+// (0 to keep forever) are closed.
+type probeHandler struct {
+	cfg          *probeConfig
+	newExporter  func(dsn string) *Exporter
+	maxExporters int
+	ttl          time.Duration
+	now          func() time.Time
+
+	m         sync.Mutex
+	exporters map[string]*probeExporter // key - auth module and target
+}
+
+// newProbeHandler returns a new probe handler which creates exporters with given function,
+// and keeps at most maxExporters of them for at most ttl since last probe.
+func newProbeHandler(cfg *probeConfig, newExporter func(dsn string) *Exporter, maxExporters int, ttl time.Duration) *probeHandler {
+	return &probeHandler{
+		cfg:          cfg,
+		newExporter:  newExporter,
+		maxExporters: maxExporters,
+		ttl:          ttl,
+		now:          time.Now,
+		exporters:    make(map[string]*probeExporter),
+	}
+}
+
+// exporter returns a cached or a new exporter for given key, and closes evicted exporters.
+func (h *probeHandler) exporter(key, dsn string) (*probeExporter, error) {
+	e, evicted := h.cached(key)
+	if e == nil {
+		// new exporter is created without holding the lock; it is dropped if another probe was faster
+		created := &probeExporter{
+			exporter: h.newExporter(dsn),
+			registry: prometheus.NewRegistry(),
+		}
+		if err := created.registry.Register(lazyExporter{created.exporter}); err != nil {
+			created.exporter.Close()
+			return nil, err
+		}
+		var more []*probeExporter
+		e, more = h.insert(key, created)
+		evicted = append(evicted, more...)
+		if e != created {
+			created.exporter.Close()
+		}
+	}
+
+	// wait for in-flight probes of evicted exporters
+	for _, e := range evicted {
+		e.m.Lock()
+		e.evicted = true
+		if err := e.exporter.Close(); err != nil {
+			log.Errorln("Error closing exporter:", err)
+		}
+		e.m.Unlock()
+	}
+	return e, nil
+}
+
+// cached removes exporters unused for ttl, and returns a cached exporter for given key (or nil) and removed ones.
+func (h *probeHandler) cached(key string) (*probeExporter, []*probeExporter) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	now := h.now()
+	var evicted []*probeExporter
+	for k, e := range h.exporters {
+		if h.ttl > 0 && now.Sub(e.lastUsed) >= h.ttl {
+			evicted = append(evicted, e)
+			delete(h.exporters, k)
+		}
+	}
+
+	e := h.exporters[key]
+	if e != nil {
+		e.lastUsed = now
+	}
+	return e, evicted
+}
+
+// insert adds given exporter for given key unless there is one already, removing least recently used exporters
+// if there are too many. It returns exporter for given key and removed ones.
+func (h *probeHandler) insert(key string, e *probeExporter) (*probeExporter, []*probeExporter) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	now := h.now()
+	if cur := h.exporters[key]; cur != nil {
+		cur.lastUsed = now
+		return cur, nil
+	}
+
+	var evicted []*probeExporter
+	for h.maxExporters > 0 && len(h.exporters) >= h.maxExporters {
+		var lruKey string
+		for k, e := range h.exporters {
+			if lruKey == "" || e.lastUsed.Before(h.exporters[lruKey].lastUsed) {
+				lruKey = k
+			}
+		}
+		evicted = append(evicted, h.exporters[lruKey])
+		delete(h.exporters, lruKey)
+	}
+	e.lastUsed = now
+	h.exporters[key] = e
+	return e, evicted
+}
+
+// check interface
+var _ http.Handler = (*probeHandler)(nil)
+
+// ServeHTTP implements http.Handler.
+func (h *probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	moduleName := r.URL.Query().Get("auth_module")
+	if moduleName == "" {
+		moduleName = defaultAuthModule
+	}
+	module, ok := h.cfg.AuthModules[moduleName]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown auth_module %q", moduleName), http.StatusBadRequest)
+		return
+	}
+
+	// scrapes of the same target are serialized to keep exporter's state consistent;
+	// exporter could be evicted by concurrent probe while we were waiting for it
+	key := moduleName + " " + target
+	var e *probeExporter
+	for {
+		var err error
+		if e, err = h.exporter(key, module.dsn(target)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		e.m.Lock()
+		if !e.evicted {
+			break
+		}
+		e.m.Unlock()
+	}
+	defer e.m.Unlock()
+	promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{
+		ErrorLog:      log.NewErrorLogger(),
+		ErrorHandling: promhttp.ContinueOnError,
+	}).ServeHTTP(w, r)
+}
@@ -0,0 +1,180 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProbeConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "proxysql_exporter")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`
+auth_modules:
+  default:
+    user: stats
+    password: stats
+  admin:
+    user: admin
+    password: secret
+`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	cfg, err := loadProbeConfig(f.Name())
+	require.NoError(t, err)
+	expected := &probeConfig{
+		AuthModules: map[string]authModule{
+			"default": {User: "stats", Password: "stats"},
+			"admin":   {User: "admin", Password: "secret"},
+		},
+	}
+	assert.Equal(t, expected, cfg)
+	assert.Equal(t, "admin:secret@tcp(10.91.142.80:6032)/", cfg.AuthModules["admin"].dsn("10.91.142.80:6032"))
+
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte("auth_modules: {}\n"), 0600))
+	_, err = loadProbeConfig(f.Name())
+	assert.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte("modules: {}\n"), 0600))
+	_, err = loadProbeConfig(f.Name())
+	assert.Error(t, err)
+}
+
+func TestProbeHandler(t *testing.T) {
+	cfg := &probeConfig{
+		AuthModules: map[string]authModule{
+			"default": {User: "stats", Password: "stats"},
+		},
+	}
+	var dsns []string
+	h := newProbeHandler(cfg, func(dsn string) *Exporter {
+		dsns = append(dsns, dsn)
+		return NewExporter(dsn, ExporterOptions{})
+	}, 0, 0)
+
+	probe := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/probe"+query, nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusBadRequest, probe("").Code)
+	assert.Equal(t, http.StatusBadRequest, probe("?target=127.0.0.1:1&auth_module=admin").Code)
+
+	// nothing listens on port 1, so ProxySQL is down;
+	// exporter is scraped only by probe itself, not by registration
+	rec := probe("?target=127.0.0.1:1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "proxysql_up 0")
+	assert.Contains(t, rec.Body.String(), "proxysql_exporter_scrapes_total 1")
+
+	// the same exporter is used for the same target
+	rec = probe("?target=127.0.0.1:1&auth_module=default")
+	assert.Contains(t, rec.Body.String(), "proxysql_exporter_scrapes_total 2")
+	assert.Equal(t, []string{"stats:stats@tcp(127.0.0.1:1)/"}, dsns)
+}
+
+func TestProbeHandlerConcurrentCreate(t *testing.T) {
+	var h *probeHandler
+	var exporters []*Exporter
+	h = newProbeHandler(&probeConfig{}, func(dsn string) *Exporter {
+		e := NewExporter(dsn, ExporterOptions{})
+		exporters = append(exporters, e)
+
+		// another probe of the same target creates its exporter first
+		if len(exporters) == 1 {
+			_, err := h.exporter("default 127.0.0.1:1", dsn)
+			require.NoError(t, err)
+		}
+		return e
+	}, 0, 0)
+
+	e, err := h.exporter("default 127.0.0.1:1", "stats:stats@tcp(127.0.0.1:1)/")
+	require.NoError(t, err)
+	require.Len(t, exporters, 2)
+	assert.Equal(t, exporters[1], e.exporter)
+	assert.Len(t, h.exporters, 1)
+
+	// exporter of the slower probe is closed
+	for i, closed := range []bool{true, false} {
+		exporters[i].dbM.Lock()
+		assert.Equal(t, closed, exporters[i].dbClosed)
+		exporters[i].dbM.Unlock()
+	}
+}
+
+func TestProbeHandlerEviction(t *testing.T) {
+	cfg := &probeConfig{
+		AuthModules: map[string]authModule{
+			"default": {User: "stats", Password: "stats"},
+		},
+	}
+	exporters := make(map[string]*Exporter)
+	h := newProbeHandler(cfg, func(dsn string) *Exporter {
+		e := NewExporter(dsn, ExporterOptions{})
+		exporters[dsn] = e
+		return e
+	}, 2, time.Minute)
+	now := time.Unix(1538096142, 0)
+	h.now = func() time.Time { return now }
+
+	probe := func(target string) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/probe?target="+target, nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		now = now.Add(time.Second)
+	}
+	closed := func(target string) bool {
+		e := exporters["stats:stats@tcp("+target+")/"]
+		require.NotNil(t, e, "%s", target)
+		e.dbM.Lock()
+		defer e.dbM.Unlock()
+		return e.dbClosed
+	}
+
+	// the least recently used exporter is closed
+	probe("127.0.0.1:1")
+	probe("127.0.0.1:2")
+	probe("127.0.0.1:1")
+	probe("127.0.0.1:3")
+	assert.False(t, closed("127.0.0.1:1"))
+	assert.True(t, closed("127.0.0.1:2"))
+	assert.False(t, closed("127.0.0.1:3"))
+	assert.Len(t, h.exporters, 2)
+
+	// unused exporters are closed after TTL
+	now = now.Add(time.Minute)
+	probe("127.0.0.1:4")
+	assert.True(t, closed("127.0.0.1:1"))
+	assert.True(t, closed("127.0.0.1:3"))
+	assert.False(t, closed("127.0.0.1:4"))
+	assert.Len(t, h.exporters, 1)
+
+	// evicted target gets a new exporter
+	probe("127.0.0.1:2")
+	assert.False(t, closed("127.0.0.1:2"))
+	assert.Len(t, h.exporters, 2)
+}
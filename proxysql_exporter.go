@@ -17,10 +17,12 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"regexp"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/percona/exporter_shared"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/log"
@@ -36,14 +38,68 @@ var (
 	versionF       = flag.Bool("version", false, "Print version information and exit.")
 	listenAddressF = flag.String("web.listen-address", ":42004", "Address to listen on for web interface and telemetry.")
 	telemetryPathF = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics.")
+	authFileF      = flag.String("web.auth-file", "", "Path to YAML file with server_user, server_password options for http basic auth (overrides HTTP_AUTH env var).")
+	sslCertFileF   = flag.String("web.ssl-cert-file", "", "Path to SSL certificate file.")
+	sslKeyFileF    = flag.String("web.ssl-key-file", "", "Path to SSL key file.")
+
+	dbMaxOpenConnsF    = flag.Int("db.max-open-conns", 3, "Maximum number of open connections to ProxySQL admin interface per instance, 0 for unlimited.")
+	dbMaxIdleConnsF    = flag.Int("db.max-idle-conns", 3, "Maximum number of idle connections to ProxySQL admin interface per instance, 0 to close connections after each scrape.")
+	dbConnMaxLifetimeF = flag.Duration("db.conn-max-lifetime", 5*time.Minute, "Maximum amount of time a connection to ProxySQL admin interface may be reused, 0 to reuse forever.")
+
+	instancesConfigF = flag.String("config.instances", "", "Path to YAML file with ProxySQL instances to export from /metrics, empty to use DATA_SOURCE_NAME.")
+
+	probeConfigF        = flag.String("probe.config", "", "Path to YAML file with auth_modules for probe endpoint, empty to disable it.")
+	probeListenAddressF = flag.String("web.probe-listen-address", ":42005", "Address to listen on for probe and service discovery endpoints.")
+	probePathF          = flag.String("web.probe-path", "/probe", "Path under which to expose probe endpoint.")
+	probeCacheSizeF     = flag.Int("probe.cache-size", 100, "Maximum number of probed targets to keep exporters for, least recently probed are closed first, 0 for unlimited.")
+	probeCacheTTLF      = flag.Duration("probe.cache-ttl", 10*time.Minute, "Time after the last probe to keep target's exporter for, 0 to keep forever.")
+
+	discoveryF         = flag.Bool("discovery.proxysql_servers", false, "Discover ProxySQL Cluster peers from runtime_proxysql_servers of DATA_SOURCE_NAME instance and export all of them from /metrics.")
+	discoveryIntervalF = flag.Duration("discovery.interval", time.Minute, "Interval between ProxySQL Cluster peers or Kubernetes pods discoveries.")
+	sdPathF            = flag.String("web.sd-path", "/sd", "Path under which to expose discovered instances for Prometheus HTTP service discovery, requires -probe.config.")
+
+	kubernetesF           = flag.Bool("discovery.kubernetes", false, "Discover ProxySQL pods in Kubernetes and export all of them from /metrics.")
+	kubernetesNamespaceF  = flag.String("discovery.kubernetes.namespace", "", "Namespace of ProxySQL pods, empty to use the namespace of exporter's pod or kubeconfig context.")
+	kubernetesSelectorF   = flag.String("discovery.kubernetes.selector", "app=proxysql", "Label selector of ProxySQL pods.")
+	kubernetesKubeconfigF = flag.String("discovery.kubernetes.kubeconfig", "", "Path to kubeconfig file, empty to use in-cluster service account.")
 
 	mysqlStatusF                 = flag.Bool("collect.mysql_status", true, "Collect from stats_mysql_global (SHOW MYSQL STATUS).")
 	mysqlConnectionPoolF         = flag.Bool("collect.mysql_connection_pool", true, "Collect from stats_mysql_connection_pool.")
 	mysqlConnectionListF         = flag.Bool("collect.mysql_connection_list", true, "Collect connection list from stats_mysql_processlist.")
 	mysqlDetailedConnectionListF = flag.Bool("collect.detailed.stats_mysql_processlist", false, "Collect detailed connection list from stats_mysql_processlist.")
 	memoryMetricsF               = flag.Bool("collect.stats_memory_metrics", false, "Collect memory metrics from stats_memory_metrics.")
+	queryDigestF                 = flag.Bool("collect.stats_mysql_query_digest", false, "Collect from stats_mysql_query_digest.")
+	commandsCountersF            = flag.Bool("collect.stats_mysql_commands_counters", false, "Collect latency histograms from stats_mysql_commands_counters.")
+	mysqlErrorsF                 = flag.Bool("collect.stats_mysql_errors", false, "Collect from stats_mysql_errors.")
+	monitorF                     = flag.Bool("collect.monitor", false, "Collect from ping, connect, read_only and replication lag logs in monitor schema.")
+	runtimeMySQLServersF         = flag.Bool("collect.runtime_mysql_servers", false, "Collect from runtime_mysql_servers.")
+	hostgroupsF                  = flag.Bool("collect.runtime_hostgroups", false, "Collect from runtime replication, group replication, Galera and Aurora hostgroups tables.")
+	proxySQLServersF             = flag.Bool("collect.stats_proxysql_servers", false, "Collect ProxySQL Cluster peers status, metrics and checksums from stats_proxysql_servers_* tables.")
+	mysqlUsersF                  = flag.Bool("collect.stats_mysql_users", false, "Collect from stats_mysql_users.")
+	queryRulesF                  = flag.Bool("collect.stats_mysql_query_rules", false, "Collect from stats_mysql_query_rules.")
+	freeConnectionsF             = flag.Bool("collect.stats_mysql_free_connections", false, "Collect from stats_mysql_free_connections.")
+	preparedStatementsF          = flag.Bool("collect.stats_mysql_prepared_statements_info", false, "Collect from stats_mysql_prepared_statements_info.")
+	gtidExecutedF                = flag.Bool("collect.stats_mysql_gtid_executed", false, "Collect from stats_mysql_gtid_executed and compute GTID lag of readers.")
+	statsHistoryF                = flag.Bool("collect.stats_history", false, "Collect the latest values from stats_history schema.")
+	globalVariablesF             = flag.Bool("collect.runtime_global_variables", false, "Collect from runtime_global_variables.")
+	runtimeChecksumsF            = flag.Bool("collect.runtime_checksums_values", false, "Collect from runtime_checksums_values and count checksum changes.")
+	clientHostCacheF             = flag.Bool("collect.stats_mysql_client_host_cache", false, "Collect from stats_mysql_client_host_cache.")
+
+	queryDigestLimitF   = flag.Int("collect.stats_mysql_query_digest.limit", 50, "Number of top query digests to collect, the rest is summed into proxysql_query_digest_other_* gauges.")
+	queryDigestOrderByF = flag.String("collect.stats_mysql_query_digest.order_by", queryDigestOrderBySumTime, "Column to select top query digests by: sum_time or count_star.")
+	queryRulesCommentF  = flag.Bool("collect.stats_mysql_query_rules.comment", false, "Add query rule comment as a label.")
+
+	preparedStatementsLimitF = flag.Int("collect.stats_mysql_prepared_statements_info.limit", 20, "Number of top prepared statements by client references to collect by digest, 0 to disable.")
+
+	globalVariablesIncludeF = flag.String("collect.runtime_global_variables.include", "", "Regular expression of global variable names to collect, empty to collect all.")
+	globalVariablesExcludeF = flag.String("collect.runtime_global_variables.exclude", "password|credentials", "Regular expression of global variable names to skip, empty to skip none.")
+
+	clientHostCacheLimitF = flag.Int("collect.stats_mysql_client_host_cache.limit", 20, "Number of top client hosts by error count to collect by client address, 0 to disable.")
 )
 
+// global variables filters compiled from flags once, shared by all exporters
+var globalVariablesInclude, globalVariablesExclude *regexp.Regexp
+
 func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "%s %s exports various ProxySQL metrics in Prometheus format.\n", os.Args[0], version.Version)
@@ -60,15 +116,175 @@ func main() {
 		os.Exit(0)
 	}
 
-	dsn := os.Getenv("DATA_SOURCE_NAME")
-	if dsn == "" {
-		dsn = defaultDataSource
+	var err error
+	if globalVariablesInclude, err = compileGlobalVariablesFilter(*globalVariablesIncludeF); err != nil {
+		log.Fatalf("Invalid runtime_global_variables filter %q: %s", *globalVariablesIncludeF, err)
 	}
+	if globalVariablesExclude, err = compileGlobalVariablesFilter(*globalVariablesExcludeF); err != nil {
+		log.Fatalf("Invalid runtime_global_variables filter %q: %s", *globalVariablesExcludeF, err)
+	}
+
+	var modes int
+	for _, enabled := range []bool{*discoveryF, *kubernetesF, *instancesConfigF != ""} {
+		if enabled {
+			modes++
+		}
+	}
+	if modes > 1 {
+		log.Fatal("Flags -discovery.proxysql_servers, -discovery.kubernetes and -config.instances are mutually exclusive.")
+	}
+
+	// the same authentication and HTTPS settings are used for metrics and for probe and service discovery endpoints
+	serverCfg, err := readServerConfig(*authFileF, *sslCertFileF, *sslKeyFileF)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if serverCfg.Username != "" && serverCfg.Password != "" {
+		log.Infoln("HTTP Basic authentication is enabled.")
+	}
+
+	var sd http.Handler // service discovery endpoint handler of discovery modes
+
+	switch {
+	case *discoveryF:
+		dsn := os.Getenv("DATA_SOURCE_NAME")
+		if dsn == "" {
+			dsn = defaultDataSource
+		}
+
+		log.Infof("Starting %s %s for ProxySQL Cluster peers of %s", program, version.Version, dsn)
+
+		discovery := newClusterDiscovery(dsn, newExporter)
+		if err := discovery.refresh(); err != nil {
+			log.Errorln("Error discovering ProxySQL Cluster peers:", err)
+		}
+		go runDiscovery(discovery.refresh, *discoveryIntervalF)
+		prometheus.DefaultGatherer = prometheus.Gatherers{prometheus.DefaultGatherer, discovery}
+
+		sd = discovery
+
+	case *kubernetesF:
+		dsn := os.Getenv("DATA_SOURCE_NAME")
+		if dsn == "" {
+			dsn = defaultDataSource
+		}
+
+		var client *httpKubernetesClient
+		var namespace string
+		var err error
+		if *kubernetesKubeconfigF == "" {
+			client, namespace, err = newInClusterKubernetesClient()
+		} else {
+			client, namespace, err = newKubeconfigKubernetesClient(*kubernetesKubeconfigF)
+		}
+		if err != nil {
+			log.Fatalf("Failed to configure Kubernetes client: %s", err)
+		}
+		if *kubernetesNamespaceF != "" {
+			namespace = *kubernetesNamespaceF
+		}
+
+		log.Infof("Starting %s %s for Kubernetes pods %q in namespace %q", program, version.Version, *kubernetesSelectorF, namespace)
+
+		discovery := newKubernetesDiscovery(client, namespace, *kubernetesSelectorF, dsn, newExporter)
+		if err = discovery.refresh(); err != nil {
+			log.Errorln("Error discovering Kubernetes pods:", err)
+		}
+		go runDiscovery(discovery.refresh, *discoveryIntervalF)
+		prometheus.DefaultGatherer = prometheus.Gatherers{prometheus.DefaultGatherer, discovery}
+
+		sd = discovery
+
+	case *instancesConfigF == "":
+		dsn := os.Getenv("DATA_SOURCE_NAME")
+		if dsn == "" {
+			dsn = defaultDataSource
+		}
+
+		log.Infof("Starting %s %s for %s", program, version.Version, dsn)
+
+		prometheus.MustRegister(newExporter(dsn))
+
+	default:
+		cfg, err := loadInstancesConfig(*instancesConfigF)
+		if err != nil {
+			log.Fatalf("Failed to load instances config: %s", err)
+		}
+
+		log.Infof("Starting %s %s for %d instances", program, version.Version, len(cfg.Instances))
+
+		gatherers := make(instancesGatherer, len(cfg.Instances))
+		for i, inst := range cfg.Instances {
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(newExporter(inst.DSN))
+			gatherers[i] = newInstanceGatherer(inst, registry)
+		}
+		prometheus.DefaultGatherer = prometheus.Gatherers{prometheus.DefaultGatherer, gatherers}
+	}
+
+	// discovered instances are ProxySQL admin interfaces which can be scraped only via probe endpoint
+	switch {
+	case *probeConfigF != "":
+		cfg, err := loadProbeConfig(*probeConfigF)
+		if err != nil {
+			log.Fatalf("Failed to load probe config: %s", err)
+		}
+		endpoints := http.NewServeMux()
+		endpoints.Handle(*probePathF, newProbeHandler(cfg, newExporter, *probeCacheSizeF, *probeCacheTTLF))
+		if sd != nil {
+			endpoints.Handle(*sdPathF, sd)
+		}
+		go serverCfg.run(*probeListenAddressF, serverCfg.handler(endpoints))
+
+	case sd != nil:
+		log.Warnf("Service discovery endpoint %s is disabled as it requires probe endpoint enabled by -probe.config flag.", *sdPathF)
+	}
+
+	handler := promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+		ErrorLog:      log.NewErrorLogger(),
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+	mux := http.NewServeMux()
+	mux.Handle(*telemetryPathF, serverCfg.handler(handler))
+	mux.Handle("/", serverCfg.landingHandler(*telemetryPathF))
+	serverCfg.run(*listenAddressF, mux)
+}
 
-	log.Infof("Starting %s %s for %s", program, version.Version, dsn)
+// newExporter returns a new exporter for the provided DSN with collectors and settings given by flags.
+func newExporter(dsn string) *Exporter {
+	return NewExporter(dsn, ExporterOptions{
+		ScrapeMySQLGlobal:              *mysqlStatusF,
+		ScrapeMySQLConnectionPool:      *mysqlConnectionPoolF,
+		ScrapeMySQLConnectionList:      *mysqlConnectionListF,
+		ScrapeDetailedMySQLProcessList: *mysqlDetailedConnectionListF,
+		ScrapeMemoryMetrics:            *memoryMetricsF,
+		ScrapeMySQLQueryDigest:         *queryDigestF,
+		ScrapeMySQLCommandsCounters:    *commandsCountersF,
+		ScrapeMySQLErrors:              *mysqlErrorsF,
+		ScrapeMonitor:                  *monitorF,
+		ScrapeRuntimeMySQLServers:      *runtimeMySQLServersF,
+		ScrapeHostgroups:               *hostgroupsF,
+		ScrapeProxySQLServers:          *proxySQLServersF,
+		ScrapeMySQLUsers:               *mysqlUsersF,
+		ScrapeMySQLQueryRules:          *queryRulesF,
+		ScrapeMySQLFreeConnections:     *freeConnectionsF,
+		ScrapePreparedStatements:       *preparedStatementsF,
+		ScrapeGTIDExecuted:             *gtidExecutedF,
+		ScrapeStatsHistory:             *statsHistoryF,
+		ScrapeGlobalVariables:          *globalVariablesF,
+		ScrapeRuntimeChecksums:         *runtimeChecksumsF,
+		ScrapeClientHostCache:          *clientHostCacheF,
 
-	exporter := NewExporter(dsn, *mysqlStatusF, *mysqlConnectionPoolF, *mysqlConnectionListF, *mysqlDetailedConnectionListF, *memoryMetricsF)
-	prometheus.MustRegister(exporter)
+		QueryDigestLimit:        *queryDigestLimitF,
+		QueryDigestOrderBy:      *queryDigestOrderByF,
+		QueryRulesComment:       *queryRulesCommentF,
+		PreparedStatementsLimit: *preparedStatementsLimitF,
+		GlobalVariablesInclude:  globalVariablesInclude,
+		GlobalVariablesExclude:  globalVariablesExclude,
+		ClientHostCacheLimit:    *clientHostCacheLimitF,
 
-	exporter_shared.RunServer("ProxySQL", *listenAddressF, *telemetryPathF, promhttp.ContinueOnError)
+		DBMaxOpenConns:    *dbMaxOpenConnsF,
+		DBMaxIdleConns:    *dbMaxIdleConnsF,
+		DBConnMaxLifetime: *dbConnMaxLifetimeF,
+	})
 }
@@ -0,0 +1,149 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/prometheus/common/log"
+	"gopkg.in/yaml.v2"
+)
+
+var landingPage = template.Must(template.New("home").Parse(strings.TrimSpace(`
+<html>
+<head>
+	<title>ProxySQL exporter</title>
+</head>
+<body>
+	<h1>ProxySQL exporter</h1>
+	<p><a href="{{ . }}">Metrics</a></p>
+</body>
+</html>
+`)))
+
+// serverConfig contains HTTP Basic authentication and HTTPS settings shared by metrics server
+// and probe and service discovery endpoints server.
+type serverConfig struct {
+	Username string `yaml:"server_user,omitempty"`
+	Password string `yaml:"server_password,omitempty"`
+	certFile string
+	keyFile  string
+}
+
+// readServerConfig returns server settings with credentials from given auth file or HTTP_AUTH environment variable,
+// and given SSL certificate and key files.
+func readServerConfig(authFile, certFile, keyFile string) (*serverConfig, error) {
+	var cfg serverConfig
+	httpAuth := os.Getenv("HTTP_AUTH")
+	switch {
+	case authFile != "":
+		b, err := ioutil.ReadFile(authFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read auth file %q: %s", authFile, err)
+		}
+		if err = yaml.Unmarshal(b, &cfg); err != nil {
+			return nil, fmt.Errorf("cannot parse auth file %q: %s", authFile, err)
+		}
+	case httpAuth != "":
+		data := strings.SplitN(httpAuth, ":", 2)
+		if len(data) != 2 || data[0] == "" || data[1] == "" {
+			return nil, fmt.Errorf("HTTP_AUTH should be formatted as user:password")
+		}
+		cfg.Username = data[0]
+		cfg.Password = data[1]
+	}
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("one of the flags -web.ssl-cert-file or -web.ssl-key-file is missing to enable HTTPS")
+	}
+	for _, f := range []string{certFile, keyFile} {
+		if f == "" {
+			continue
+		}
+		if _, err := os.Stat(f); err != nil {
+			return nil, fmt.Errorf("SSL file: %s", err)
+		}
+	}
+	cfg.certFile = certFile
+	cfg.keyFile = keyFile
+	return &cfg, nil
+}
+
+// handler returns given handler wrapped with HTTP Basic authentication check if it is enabled.
+func (cfg *serverConfig) handler(handler http.Handler) http.Handler {
+	if cfg.Username == "" || cfg.Password == "" {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		usernameOk := subtle.ConstantTimeCompare([]byte(cfg.Username), []byte(username)) == 1
+		passwordOk := subtle.ConstantTimeCompare([]byte(cfg.Password), []byte(password)) == 1
+		if !usernameOk || !passwordOk {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// landingHandler returns a handler of landing page with a link to given metrics path.
+func (cfg *serverConfig) landingHandler(path string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.certFile != "" {
+			w.Header().Add("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+		}
+		if err := landingPage.Execute(w, path); err != nil {
+			log.Errorln("Error rendering landing page:", err)
+		}
+	})
+}
+
+// run serves given handler on given address with HTTPS if it is enabled.
+// Function never returns.
+func (cfg *serverConfig) run(addr string, handler http.Handler) {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+	if cfg.certFile == "" {
+		log.Infof("Starting HTTP server on %s ...", addr)
+		log.Fatal(srv.ListenAndServe())
+	}
+
+	srv.TLSConfig = &tls.Config{
+		MinVersion:               tls.VersionTLS12,
+		CurvePreferences:         []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
+		PreferServerCipherSuites: true,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+		},
+	}
+	srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler)) // disable HTTP/2
+	log.Infof("Starting HTTPS server on %s ...", addr)
+	log.Fatal(srv.ListenAndServeTLS(cfg.certFile, cfg.keyFile))
+}
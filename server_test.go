@@ -0,0 +1,67 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadServerConfig(t *testing.T) {
+	defer os.Setenv("HTTP_AUTH", os.Getenv("HTTP_AUTH"))
+	require.NoError(t, os.Setenv("HTTP_AUTH", "user:secret"))
+
+	cfg, err := readServerConfig("", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, &serverConfig{Username: "user", Password: "secret"}, cfg)
+
+	f, err := ioutil.TempFile("", "proxysql_exporter")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("server_user: file_user\nserver_password: file_secret\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// auth file overrides HTTP_AUTH
+	cfg, err = readServerConfig(f.Name(), "", "")
+	require.NoError(t, err)
+	assert.Equal(t, &serverConfig{Username: "file_user", Password: "file_secret"}, cfg)
+
+	_, err = readServerConfig(f.Name(), f.Name(), "")
+	assert.Error(t, err)
+	_, err = readServerConfig(f.Name(), f.Name(), "missing.pem")
+	assert.Error(t, err)
+
+	handler := cfg.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/probe?target=127.0.0.1:1", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest("GET", "/probe?target=127.0.0.1:1", nil)
+	req.SetBasicAuth("file_user", "file_secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	cfg.landingHandler("/metrics").ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.Contains(t, rec.Body.String(), `<a href="/metrics">Metrics</a>`)
+}